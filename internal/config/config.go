@@ -4,6 +4,8 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -14,6 +16,26 @@ func Load() (cfg Config, err error) {
 	flag.StringVar(&cfg.GithubToken, "github-token", os.Getenv("GITHUB_TOKEN"), "GitHub token")
 	flag.StringVar(&cfg.YAMLFile, "yaml-file", os.Getenv("INPUT_YAMLFILE"), "Path to YAML file")
 	flag.StringVar(&cfg.DestinationDir, "destination-dir", os.Getenv("INPUT_DESTINATIONDIR"), "Destination directory")
+	flag.StringVar(&cfg.Draft, "draft", envString("INPUT_DRAFT", "draft-07"), "JSON Schema draft of the output (draft-07, 2019-09, 2020-12)")
+	flag.BoolVar(&cfg.Strict, "strict", envBool("INPUT_STRICT", false), "Report unknown annotation keywords as errors")
+	flag.Func("disable-lint", "Comma separated list of lint rules to disable, \"all\" disables them all", func(v string) error {
+		cfg.DisabledLintRules = append(cfg.DisabledLintRules, splitList(v)...)
+		return nil
+	})
+	cfg.DisabledLintRules = splitList(os.Getenv("INPUT_DISABLELINT"))
+	flag.StringVar(&cfg.OrderAnnotation, "order-annotation", os.Getenv("INPUT_ORDERANNOTATION"), "Emit the position of each property under this annotation (x-order, propertyOrder)")
+	flag.BoolVar(&cfg.RequiredByDefault, "required-by-default", envBool("INPUT_REQUIREDBYDEFAULT", true), "Make the keys without annotation required")
+	flag.BoolVar(&cfg.DisableRequired, "disable-required", envBool("INPUT_DISABLEREQUIRED", false), "Do not emit any required property, annotations included")
+	flag.StringVar(&cfg.AdditionalProperties, "additional-properties", envString("INPUT_ADDITIONALPROPERTIES", "false"), "additionalProperties of the mappings without annotation (true, false, inherit)")
+	flag.BoolVar(&cfg.AnchorDefs, "anchor-defs", envBool("INPUT_ANCHORDEFS", false), "Emit anchored mappings as $defs referenced by $ref")
+	flag.StringVar(&cfg.DefsFile, "defs-file", os.Getenv("INPUT_DEFSFILE"), "JSON or YAML file declaring the definitions referenced by name from annotations")
+	flag.BoolVar(&cfg.BundleRefs, "bundle-refs", envBool("INPUT_BUNDLEREFS", false), "Place the referenced schema files once under $defs instead of inlining them")
+	flag.StringVar(&cfg.Documents, "documents", envString("INPUT_DOCUMENTS", "split"), "Conversion of multi-document files: split (one schema per document), oneOf, merge")
+	flag.StringVar(&cfg.EmptyMaps, "empty-maps", envString("INPUT_EMPTYMAPS", "exact"), "Schema of the empty mappings: exact (only {}), open (any key)")
+	flag.StringVar(&cfg.EmptyLists, "empty-lists", envString("INPUT_EMPTYLISTS", "open"), "Items of the empty lists: exact (no item), open (any item)")
+	flag.StringVar(&cfg.Nulls, "nulls", envString("INPUT_NULLS", "exact"), "Type of the null values: exact (null), open (any value)")
+	flag.BoolVar(&cfg.Normalize, "normalize", envBool("INPUT_NORMALIZE", true), "Remove duplicate and single-branch anyOf, oneOf and allOf")
+	flag.IntVar(&cfg.MaxErrors, "max-errors", envInt("INPUT_MAXERRORS", 0), "Stop after this many errors (0 means no limit)")
 
 	flag.CommandLine.SetOutput(os.Stderr)
 
@@ -30,4 +52,66 @@ type Config struct {
 	GithubToken    string
 	YAMLFile       string
 	DestinationDir string
+	MaxErrors      int
+	Draft          string
+	Strict         bool
+	// DisabledLintRules lists the lint rules not to run on annotations
+	DisabledLintRules []string
+	// RequiredByDefault makes the keys without annotation required
+	RequiredByDefault bool
+	// DisableRequired drops every required list
+	DisableRequired bool
+	// AdditionalProperties is the additionalProperties mode of the mappings without annotation
+	AdditionalProperties string
+	// AnchorDefs emits anchored mappings as $defs referenced by $ref
+	AnchorDefs bool
+	// DefsFile declares the definitions referenced by name from annotations
+	DefsFile string
+	// BundleRefs places the referenced schema files under $defs
+	BundleRefs bool
+	// Documents is the conversion mode of multi-document files
+	Documents string
+	// EmptyMaps, EmptyLists and Nulls are the placeholder modes of empty mappings, empty lists and nulls
+	EmptyMaps  string
+	EmptyLists string
+	Nulls      string
+	// Normalize simplifies the combinators of the generated schema
+	Normalize bool
+	// OrderAnnotation is the annotation holding the position of each property, if any
+	OrderAnnotation string
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(v string) []string {
+	result := []string{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// envBool returns the boolean value of the given environment variable or def when unset or invalid
+func envBool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// envString returns the value of the given environment variable or def when unset
+func envString(key string, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt returns the integer value of the given environment variable or def when unset or invalid
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
 }
@@ -18,12 +18,31 @@ func parse(pointer string) ([]string, error) {
 		return nil, fmt.Errorf("invalid JSON pointer: %q", pointer)
 	}
 	for i, token := range tokens {
-		tokens[i] = strings.Replace(
-			strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		tokens[i] = Unescape(token)
 	}
 	return tokens, nil
 }
 
+// Escape escapes a reference token so that it can be appended to a pointer.
+func Escape(token string) string {
+	return strings.Replace(
+		strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+// Unescape returns the reference token escaped by Escape.
+func Unescape(token string) string {
+	return strings.Replace(
+		strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+}
+
+// Append returns the pointer extended with the given reference tokens.
+func Append(pointer string, tokens ...string) string {
+	for _, token := range tokens {
+		pointer += "/" + Escape(token)
+	}
+	return pointer
+}
+
 // Has return whether the obj has pointer.
 func Has(obj interface{}, pointer string) (rv bool) {
 	defer func() {
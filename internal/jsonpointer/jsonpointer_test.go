@@ -155,3 +155,23 @@ func TestRemove(t *testing.T) {
 		}
 	}
 }
+
+var testAppendCases = []struct {
+	pointer string
+	tokens  []string
+	expect  string
+}{
+	{``, []string{"foo"}, `/foo`},
+	{`/foo`, []string{"bar", "0"}, `/foo/bar/0`},
+	{``, []string{"foo~bar/baz"}, `/foo~0bar~1baz`},
+	{`/foo`, nil, `/foo`},
+}
+
+func TestAppend(t *testing.T) {
+	for _, testcase := range testAppendCases {
+		value := Append(testcase.pointer, testcase.tokens...)
+		if value != testcase.expect {
+			t.Fatalf("expected %v, but %v:", testcase.expect, value)
+		}
+	}
+}
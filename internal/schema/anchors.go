@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/krateoplatformops/yaml-to-jsonschema/internal/jsonpointer"
+	"gopkg.in/yaml.v3"
+)
+
+// mergeTag is the tag of YAML merge keys (<<)
+const mergeTag = "!!merge"
+
+// resolveAlias returns the node an alias refers to, node itself otherwise
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// expandAlias returns the node an alias refers to, node itself otherwise.
+// An alias to a node being expanded, e.g. a: &x {b: *x}, would be expanded forever:
+// it is reported and nil is returned.
+func (c *converter) expandAlias(node *yaml.Node, pointer, key string) (*yaml.Node, error) {
+	target := resolveAlias(node)
+	if node.Kind == yaml.AliasNode && slices.Contains(c.expanding, target) {
+		return nil, c.report(c.errorAt(node, pointer, key, ErrInvalidDocument,
+			fmt.Errorf("alias *%s refers to a node containing it", node.Value)))
+	}
+	return target, nil
+}
+
+// expand marks node as being expanded until the returned function is called, see expandAlias
+func (c *converter) expand(node *yaml.Node) func() {
+	c.expanding = append(c.expanding, node)
+	return func() {
+		c.expanding = c.expanding[:len(c.expanding)-1]
+	}
+}
+
+// mappingPairs returns the key and value nodes of a mapping with its merge keys resolved.
+// The keys of the merged mappings take the place of the merge key; the keys of the mapping
+// itself override merged keys and, in a merge list, earlier mappings override later ones.
+func (c *converter) mappingPairs(node *yaml.Node, pointer string) ([]*yaml.Node, error) {
+	defer c.expand(node)()
+
+	explicit := map[string]bool{}
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if node.Content[i].Tag != mergeTag {
+			explicit[node.Content[i].Value] = true
+		}
+	}
+
+	result := []*yaml.Node{}
+	seen := map[string]bool{}
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if keyNode.Tag != mergeTag {
+			result = append(result, keyNode, valueNode)
+			seen[keyNode.Value] = true
+			continue
+		}
+
+		sources := []*yaml.Node{valueNode}
+		if resolveAlias(valueNode).Kind == yaml.SequenceNode {
+			sources = resolveAlias(valueNode).Content
+		}
+		for _, source := range sources {
+			source, err := c.expandAlias(source, pointer, keyNode.Value)
+			if err != nil {
+				return nil, err
+			}
+			if source == nil {
+				continue
+			}
+			if source.Kind != yaml.MappingNode {
+				err := c.report(c.errorAt(source, pointer, keyNode.Value, ErrInvalidDocument,
+					fmt.Errorf("merge key expects a mapping or a list of mappings, found %s", source.ShortTag())))
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			merged, err := c.mappingPairs(source, pointer)
+			if err != nil {
+				return nil, err
+			}
+			for j := 0; j < len(merged); j += 2 {
+				if key := merged[j].Value; !explicit[key] && !seen[key] {
+					result = append(result, merged[j], merged[j+1])
+					seen[key] = true
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// anchorRef returns the $ref to the definition generated once for the anchored mapping node
+func (c *converter) anchorRef(node *yaml.Node, pol policy) (string, error) {
+	if name, ok := c.anchorNames[node]; ok {
+		return "#/$defs/" + jsonpointer.Escape(name), nil
+	}
+
+	// the same anchor name can be redefined further in the document
+	name := c.defName(node.Anchor)
+	if c.anchorNames == nil {
+		c.anchorNames = map[*yaml.Node]string{}
+	}
+	c.anchorNames[node] = name
+
+	def, err := c.fromYAML(node, jsonpointer.Append("", "$defs", name), nil, pol)
+	if err != nil {
+		return "", err
+	}
+	def.AdditionalProperties = c.mappingAdditionalProperties(node, pol)
+	c.addDef(name, def)
+
+	return "#/$defs/" + jsonpointer.Escape(name), nil
+}
+
+// defName reserves and returns a definition name not taken yet, base or base-2, base-3, ...
+func (c *converter) defName(base string) string {
+	name := base
+	for i := 2; slices.Contains(c.defNames, name); i++ {
+		name = base + "-" + strconv.Itoa(i)
+	}
+	c.defNames = append(c.defNames, name)
+	return name
+}
+
+// addDef adds a generated definition, attached to the document schema by attachDefs
+func (c *converter) addDef(name string, def *Schema) {
+	if c.defs == nil {
+		c.defs = map[string]*Schema{}
+	}
+	c.defs[name] = def
+}
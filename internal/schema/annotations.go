@@ -3,6 +3,8 @@ package schema
 import (
 	"bufio"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -13,29 +15,95 @@ const (
 	SchemaPrefix  = "# @schema"
 	CommentPrefix = "#"
 
+	// RootSchemaPrefix marks the annotation of the whole document. It is read from the
+	// head comment of the document or of its first key.
+	RootSchemaPrefix = "# @schema.root"
+
 	// CustomAnnotationPrefix marks custom annotations.
 	// Custom annotations are extensions to the JSON Schema specification
 	// See: https://json-schema.org/blog/posts/custom-annotations-will-continue
 	CustomAnnotationPrefix = "x-"
+
+	// PassthroughAnnotation accepts any value for the annotated key: its children are not walked
+	PassthroughAnnotation = "x-passthrough"
+	// InternalAnnotation marks the annotated key as readOnly. It is kept in the schema
+	// so that documentation tools can hide the key.
+	InternalAnnotation = "x-internal"
 )
 
-// GetSchemaFromComment parses the annotations from the given comment
+// boolAnnotation returns the value of the boolean custom annotation name of s, false when unset
+func boolAnnotation(s *Schema, name string) (bool, error) {
+	value, ok := s.CustomAnnotations[name]
+	if !ok {
+		return false, nil
+	}
+	enabled, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s must be a boolean, got %v", name, value)
+	}
+	return enabled, nil
+}
+
+// yamlErrorLine extracts the line reported by yaml.v3 error messages
+var yamlErrorLine = regexp.MustCompile(`line (\d+):`)
+
+// GetSchemaFromComment parses the annotations from the given comment.
+// Errors are reported as *AnnotationError carrying the comment line they refer to.
 func GetSchemaFromComment(comment string) (Schema, string, error) {
+	return parseComment(comment, SchemaPrefix)
+}
+
+// GetRootSchemaFromComment parses the root annotation of the document from the given comment
+func GetRootSchemaFromComment(comment string) (Schema, string, error) {
+	return parseComment(comment, RootSchemaPrefix)
+}
+
+// annotationMarker returns the annotation marker starting the given comment line, if any
+func annotationMarker(line string) string {
+	switch {
+	case strings.HasPrefix(line, RootSchemaPrefix):
+		return RootSchemaPrefix
+	case strings.HasPrefix(line, SchemaPrefix):
+		return SchemaPrefix
+	}
+	return ""
+}
+
+// parseComment parses the blocks delimited by prefix in the given comment.
+// Blocks delimited by another marker are ignored, the rest of the comment is the description.
+func parseComment(comment, prefix string) (Schema, string, error) {
 	var result Schema
 	scanner := bufio.NewScanner(strings.NewReader(comment))
 	description := []string{}
 	rawSchema := []string{}
-	insideSchemaBlock := false
+	// rawLines maps each line of rawSchema to its line in the comment,
+	// rawOffsets to the number of comment characters stripped from it
+	rawLines, rawOffsets := []int{}, []int{}
+	insideSchemaBlock, insideOtherBlock := false, false
+	lineNum, schemaStart := 0, 0
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		if strings.HasPrefix(line, SchemaPrefix) {
+		lineNum++
+		switch marker := annotationMarker(line); {
+		case marker == prefix:
 			insideSchemaBlock = !insideSchemaBlock
+			if insideSchemaBlock {
+				schemaStart = lineNum
+			}
+			continue
+		case marker != "":
+			insideOtherBlock = !insideOtherBlock
+			continue
+		case insideOtherBlock:
 			continue
 		}
 		if insideSchemaBlock {
 			content := strings.TrimPrefix(line, CommentPrefix)
-			rawSchema = append(rawSchema, strings.TrimPrefix(strings.TrimPrefix(content, CommentPrefix), " "))
+			content = strings.TrimPrefix(strings.TrimPrefix(content, CommentPrefix), " ")
+			rawSchema = append(rawSchema, content)
+			rawLines = append(rawLines, lineNum)
+			rawOffsets = append(rawOffsets, len(line)-len(content))
 			result.Set()
 		} else {
 			description = append(description, strings.TrimPrefix(strings.TrimPrefix(line, CommentPrefix), " "))
@@ -43,14 +111,32 @@ func GetSchemaFromComment(comment string) (Schema, string, error) {
 	}
 
 	if insideSchemaBlock {
-		return result, "",
-			fmt.Errorf("unclosed schema block found in comment: %s", comment)
+		return result, "", &AnnotationError{
+			Line: schemaStart,
+			Err:  fmt.Errorf("unclosed schema block found in comment: %s", comment),
+		}
 	}
 
 	err := yaml.Unmarshal([]byte(strings.Join(rawSchema, "\n")), &result)
 	if err != nil {
-		return result, "", err
+		line := schemaStart
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			if n, _ := strconv.Atoi(m[1]); n > 0 && n <= len(rawLines) {
+				line = rawLines[n-1]
+			}
+		}
+		return result, "", &AnnotationError{Line: line, Err: err}
 	}
 
+	// Locate unknown keywords in the comment rather than in the annotation block
+	result.walk(func(s *Schema) {
+		for i, k := range s.unknownKeywords {
+			if k.Line > 0 && k.Line <= len(rawLines) {
+				s.unknownKeywords[i].Line = rawLines[k.Line-1]
+				s.unknownKeywords[i].Column = rawOffsets[k.Line-1] + k.Column
+			}
+		}
+	})
+
 	return result, strings.Join(description, "\n"), nil
 }
@@ -1,47 +1,509 @@
 package schema
 
 import (
-	"log"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
 	"regexp"
 	"slices"
+	"strings"
 
+	"github.com/krateoplatformops/yaml-to-jsonschema/internal/jsonpointer"
 	"gopkg.in/yaml.v3"
 )
 
-// FromYAML recursively parses a YAML node and creates a JSON Schema from it
+// Options configures the conversion performed by FromYAML
+type Options struct {
+	// ValuesPath is the path of the values file being processed.
+	// Relative $ref annotations are resolved against its directory.
+	ValuesPath string
+	// MaxErrors stops the conversion once that many errors have been reported.
+	// Zero means no limit.
+	MaxErrors int
+	// Draft selects the JSON Schema draft of the output, DefaultDraft when empty.
+	// Annotations are validated against the meta-schema of this draft.
+	Draft Draft
+	// Strict reports unknown annotation keywords as errors instead of warnings
+	Strict bool
+	// DisabledLintRules lists the lint rules not to run on annotations, LintAll disables them all
+	DisabledLintRules []string
+	// Required selects which properties are listed as required, RequiredByDefault when empty
+	Required RequiredMode
+	// AdditionalProperties selects the additionalProperties of the mappings without annotation,
+	// AdditionalPropertiesFalse when empty
+	AdditionalProperties AdditionalPropertiesMode
+	// OrderAnnotation, when set to OrderAnnotation or PropertyOrderAnnotation,
+	// emits the position of each property in its parent mapping under that name
+	OrderAnnotation string
+	// AnchorDefs emits the anchored mappings as a single $defs entry referenced
+	// by $ref from each key using them, instead of duplicating their schema
+	AnchorDefs bool
+	// DisableNormalize keeps the generated combinators as they are, see Normalize
+	DisableNormalize bool
+	// Documents selects how the documents of a multi-document stream are converted,
+	// DocumentsSplit when empty
+	Documents DocumentsMode
+	// EmptyMaps selects the schema of the mappings without keys nor annotation,
+	// PlaceholderExact (only {} is accepted) when empty
+	EmptyMaps PlaceholderMode
+	// EmptyLists selects the items of the empty lists without annotation,
+	// PlaceholderOpen (any item) when empty
+	EmptyLists PlaceholderMode
+	// Nulls selects the type of the null values without annotation,
+	// PlaceholderExact (type null) when empty
+	Nulls PlaceholderMode
+	// DefsFile is the path of a JSON or YAML file declaring definitions that annotations
+	// reference by name, only the referenced ones being emitted, see UseAnnotation
+	DefsFile string
+	// BundleRefs places the schemas referenced by the annotations once under $defs,
+	// instead of copying them in place of each reference
+	BundleRefs bool
+	// Report, when set, is called with each diagnostic as soon as it is found
+	Report func(*Diagnostic)
+}
+
+// converter holds the state shared by the recursive walk of a document
+type converter struct {
+	ctx    context.Context
+	opts   Options
+	diags  Diagnostics
+	errors int
+
+	// defs holds the definitions generated for anchored mappings, see Options.AnchorDefs
+	defs map[string]*Schema
+	// defNames lists the names taken in defs, anchorNames the name given to each anchored node
+	defNames    []string
+	anchorNames map[*yaml.Node]string
+	// refNames holds the name given to each bundled reference, see Options.BundleRefs
+	refNames map[string]string
+	// refSites holds where each local reference was found, see resolveLocalRefs
+	refSites map[string]refSite
+	// expanding lists the nodes being converted, from the outermost one, see expandAlias
+	expanding []*yaml.Node
+	// library holds the definitions declared by Options.DefsFile and the root annotation,
+	// fileLibrary the ones of Options.DefsFile
+	library     map[string]*Schema
+	fileLibrary map[string]*Schema
+}
+
+// FromYAML creates a JSON Schema from the given YAML document node.
+// It never terminates the process and does not stop at the first problem:
+// keys with broken annotations fall back to tag-based inference and every
+// problem found is returned in the diagnostics list.
+// The returned error is only set when the conversion could not complete
+// (e.g. the context was canceled).
+func FromYAML(ctx context.Context, node *yaml.Node, opts Options) (*Schema, Diagnostics, error) {
+	schemas, diags, err := FromYAMLDocuments(ctx, []*yaml.Node{node}, opts)
+	if len(schemas) == 0 {
+		return nil, diags, err
+	}
+	return schemas[0], diags, err
+}
+
+// attachDefs adds the definitions generated for anchored mappings to schema
+func (c *converter) attachDefs(schema *Schema) {
+	if len(c.defs) == 0 {
+		return
+	}
+	if schema.Defs == nil {
+		schema.Defs = map[string]*Schema{}
+	}
+	maps.Copy(schema.Defs, c.defs)
+}
+
+// finish applies the options that affect the whole generated schema
+func (c *converter) finish(schema *Schema) {
+	if !c.opts.DisableNormalize {
+		normalize(schema, c.opts.Draft.orDefault())
+	}
+	if c.opts.Draft.orDefault() == Draft7 {
+		wrapRefSiblings(schema)
+	}
+	if c.opts.Required == RequiredNone {
+		schema.DisableRequiredProperties()
+	}
+	schema.SetDraft(c.opts.Draft)
+	if c.opts.OrderAnnotation != "" {
+		schema.SetPropertyOrder(c.opts.OrderAnnotation)
+	}
+}
+
+// report records a diagnostic. It returns ErrTooManyErrors once
+// the MaxErrors budget is exhausted, telling the walk to stop.
+func (c *converter) report(d *Diagnostic) error {
+	c.diags = append(c.diags, d)
+	if c.opts.Report != nil {
+		c.opts.Report(d)
+	}
+	if d.Severity != SeverityError {
+		return nil
+	}
+	c.errors++
+	if c.opts.MaxErrors > 0 && c.errors >= c.opts.MaxErrors {
+		return ErrTooManyErrors
+	}
+	return nil
+}
+
+// errorAt builds an error diagnostic located at the given node
+func (c *converter) errorAt(node *yaml.Node, pointer, key string, kind, err error) *Diagnostic {
+	return &Diagnostic{
+		Severity: SeverityError,
+		Kind:     kind,
+		File:     c.opts.ValuesPath,
+		Line:     node.Line,
+		Column:   node.Column,
+		Pointer:  pointer,
+		Key:      key,
+		Err:      err,
+	}
+}
+
+// annotationErrorAt builds an error diagnostic for a failure found in the head comment of keyNode.
+// When the failure carries its comment line, the diagnostic points at that line, without a column
+// as the comment does not keep its indentation.
+func (c *converter) annotationErrorAt(keyNode *yaml.Node, pointer string, kind, err error) *Diagnostic {
+	d := c.errorAt(keyNode, pointer, keyNode.Value, kind, err)
+
+	var annErr *AnnotationError
+	if errors.As(err, &annErr) && annErr.Line > 0 {
+		commentStart := keyNode.Line - strings.Count(keyNode.HeadComment, "\n") - 1
+		d.Line = commentStart + annErr.Line - 1
+		d.Column = 0
+	}
+	return d
+}
+
+// reportUnknownKeywords reports the unknown keywords of the annotation of keyNode,
+// as errors in strict mode and as warnings otherwise
+func (c *converter) reportUnknownKeywords(keyNode *yaml.Node, pointer string, schema *Schema) error {
+	severity := SeverityWarning
+	if c.opts.Strict {
+		severity = SeverityError
+	}
+
+	commentStart := keyNode.Line - strings.Count(keyNode.HeadComment, "\n") - 1
+	for _, k := range schema.UnknownKeywords() {
+		d := c.errorAt(keyNode, pointer, keyNode.Value, ErrUnknownKeyword, errors.New(k.String()))
+		d.Severity = severity
+		if k.Line > 0 {
+			d.Line = commentStart + k.Line - 1
+			d.Column = keyNode.Column + k.Column - 1
+		}
+		if err := c.report(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// annotation parses the annotation found in the given head comment of keyNode with parse,
+// then resolves its references and validates it. A broken annotation is reported and
+// dropped, so that the key falls back to tag-based inference.
+// The returned error is only set when the conversion must stop.
+func (c *converter) annotation(
+	keyNode *yaml.Node,
+	comment string,
+	pointer string,
+	parse func(string) (Schema, string, error),
+) (Schema, string, error) {
+	s, description, err := parse(comment)
+	if err != nil {
+		if err := c.report(c.annotationErrorAt(keyNode, pointer, ErrInvalidAnnotation, err)); err != nil {
+			return Schema{}, "", err
+		}
+		return Schema{}, "", nil
+	}
+
+	if err := c.reportUnknownKeywords(keyNode, pointer, &s); err != nil {
+		return Schema{}, "", err
+	}
+
+	// skipped keys are neither resolved nor validated
+	if s.Skip {
+		return s, description, nil
+	}
+
+	var useErr error
+	s.walk(func(sub *Schema) {
+		useErr = cmp.Or(useErr, c.useAnnotation(sub))
+	})
+	if useErr != nil {
+		if err := c.report(c.annotationErrorAt(keyNode, pointer, ErrInvalidRef, useErr)); err != nil {
+			return Schema{}, "", err
+		}
+		return Schema{}, description, nil
+	}
+
+	c.recordLocalRefs(keyNode, pointer, &s)
+
+	// Resolve the references to files anywhere in the annotation
+	if err := c.resolveRefs(&s); err != nil {
+		if err := c.report(c.annotationErrorAt(keyNode, pointer, ErrInvalidRef, err)); err != nil {
+			return Schema{}, "", err
+		}
+		return Schema{}, description, nil
+	}
+
+	if s.HasData {
+		s.SetDraft(c.opts.Draft)
+		if err := s.Validate(); err != nil {
+			if err := c.report(c.annotationErrorAt(keyNode, pointer, ErrInvalidSchema, err)); err != nil {
+				return Schema{}, "", err
+			}
+			return Schema{}, description, nil
+		}
+		for _, finding := range s.Lint(c.opts.DisabledLintRules) {
+			d := c.annotationErrorAt(keyNode, pointer, ErrLint, finding)
+			d.Severity = SeverityWarning
+			if err := c.report(d); err != nil {
+				return Schema{}, "", err
+			}
+		}
+	}
+	return s, description, nil
+}
+
+// childPolicy returns the policy of the descendants of the key annotated with s
+func (c *converter) childPolicy(keyNode *yaml.Node, pointer string, s *Schema, pol policy) (policy, error) {
+	// In inherit mode a boolean additionalProperties annotation applies to the whole subtree
+	if allowed, ok := s.AdditionalProperties.(*bool); ok && c.opts.AdditionalProperties == AdditionalPropertiesInherit {
+		pol.additionalProperties = *allowed
+	}
+
+	// Subtree directives change the policy of the key and of all its descendants.
+	// They are not emitted in the schema.
+	if directives, ok := s.CustomAnnotations[SubtreeAnnotation]; ok {
+		delete(s.CustomAnnotations, SubtreeAnnotation)
+		subtreePolicy, err := pol.withSubtree(directives)
+		if err != nil {
+			return pol, c.report(c.annotationErrorAt(keyNode, pointer, ErrInvalidAnnotation, err))
+		}
+		pol = subtreePolicy
+	}
+	return pol, nil
+}
+
+// sequenceItems infers the items schema of a sequence from its elements.
+// The schemas of the elements are merged into a single one, see mergeSchemas:
+// an anyOf is only used for elements of genuinely different shapes.
+// It returns nil for an empty sequence, see arrayItems.
+func (c *converter) sequenceItems(node *yaml.Node, pointer, key string, pol policy) (*Schema, error) {
+	itemPointer := jsonpointer.Append(pointer, "items")
+
+	defer c.expand(node)()
+
+	var items *Schema
+	for _, itemNode := range node.Content {
+		itemNode, err := c.expandAlias(itemNode, itemPointer, key)
+		if err != nil {
+			return nil, err
+		}
+		if itemNode == nil {
+			continue
+		}
+
+		var itemSchema *Schema
+		switch itemNode.Kind {
+		case yaml.ScalarNode:
+			itemNodeType, err := c.inferType(itemNode)
+			if err != nil {
+				if err := c.report(c.errorAt(itemNode, itemPointer, key, ErrUnsupportedTag, err)); err != nil {
+					return nil, err
+				}
+			}
+			itemSchema = NewSchema("")
+			itemSchema.Type = itemNodeType
+
+		case yaml.SequenceNode:
+			// a list of lists
+			itemSchema = NewSchema("array")
+			if err := c.arrayItems(itemSchema, itemNode, itemPointer, key, pol); err != nil {
+				return nil, err
+			}
+
+		default:
+			itemRequiredProperties := []string{}
+			var err error
+			itemSchema, err = c.fromYAML(itemNode, itemPointer, &itemRequiredProperties, pol)
+			if err != nil {
+				return nil, err
+			}
+			itemSchema.Required.Strings = append(itemSchema.Required.Strings, itemRequiredProperties...)
+
+			if itemNode.Kind == yaml.MappingNode && (!itemSchema.HasData || itemSchema.AdditionalProperties == nil) {
+				itemSchema.AdditionalProperties = c.mappingAdditionalProperties(itemNode, pol)
+			}
+		}
+
+		items = mergeSchemas(items, itemSchema)
+	}
+	return items, nil
+}
+
+// rootAnnotationNode returns the node whose head comment holds the root annotation of the document.
+// A comment separated from the content by a blank line belongs to the document, otherwise to
+// the content node or to its first key or item.
+func rootAnnotationNode(document *yaml.Node) *yaml.Node {
+	if !strings.Contains(document.HeadComment, RootSchemaPrefix) {
+		content := document.Content[0]
+		candidates := []*yaml.Node{content}
+		if len(content.Content) > 0 && content.Kind != yaml.AliasNode {
+			candidates = append(candidates, content.Content[0])
+		}
+		for _, candidate := range candidates {
+			if strings.Contains(candidate.HeadComment, RootSchemaPrefix) {
+				anchor := *candidate
+				// diagnostics refer to the document, not to the node
+				anchor.Value = ""
+				return &anchor
+			}
+		}
+	}
+	// The position of the document comment is not recorded by the parser,
+	// it is assumed to start at the first line
+	return &yaml.Node{
+		Line:        strings.Count(document.HeadComment, "\n") + 2,
+		Column:      1,
+		HeadComment: document.HeadComment,
+	}
+}
+
+// fromYAML recursively parses a YAML node and creates a JSON Schema from it
 // Parameters:
-//   - valuesPath: path to the values file being processed
 //   - node: current YAML node being processed
+//   - pointer: JSON pointer of the schema being built
 //   - parentRequiredProperties: list of required properties to populate in parent
-func FromYAML(
-	valuesPath string,
+//   - pol: generation policy inherited from the ancestors
+func (c *converter) fromYAML(
 	node *yaml.Node,
+	pointer string,
 	parentRequiredProperties *[]string,
-) *Schema {
+	pol policy,
+) (*Schema, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer c.expand(node)()
+
 	schema := NewSchema("object")
 
 	switch node.Kind {
 	case yaml.DocumentNode:
 		if len(node.Content) != 1 {
-			log.Fatalf("Strange yaml document found:\n%v\n", node.Content[:])
+			err := c.report(c.errorAt(node, pointer, "", ErrInvalidDocument,
+				fmt.Errorf("expected a single content node, found %d", len(node.Content))))
+			return schema, err
+		}
+
+		content := node.Content[0]
+		contentType, err := c.inferType(content)
+		if err != nil {
+			if err := c.report(c.errorAt(content, pointer, "", ErrUnsupportedTag, err)); err != nil {
+				return nil, err
+			}
+		}
+
+		// The root annotation provides the keywords of the document schema
+		rootNode := rootAnnotationNode(node)
+		root, _, err := c.annotation(rootNode, rootNode.HeadComment, pointer, GetRootSchemaFromComment)
+		if err != nil {
+			return nil, err
 		}
+		if !root.HasData {
+			schema = NewSchema("")
+			schema.Required = NewBoolOrArrayOfString([]string{}, false)
+		} else {
+			if pol, err = c.childPolicy(rootNode, pointer, &root, pol); err != nil {
+				return nil, err
+			}
+			schema = &root
+			schema.Required.Bool = false
+
+			// the declared definitions can be used by name, see UseAnnotation,
+			// and definitions generated for anchors must not replace them
+			for _, defs := range []map[string]*Schema{root.Defs, root.Definitions} {
+				for name, def := range defs {
+					if c.library == nil {
+						c.library = map[string]*Schema{}
+					}
+					c.library[name] = def
+					c.defNames = append(c.defNames, name)
+				}
+			}
+		}
+
+		schema.Schema = c.opts.Draft.URI()
+		if schema.Type.IsEmpty() {
+			schema.Type = contentType
+		}
+
+		switch content.Kind {
+		case yaml.MappingNode:
+			docSchema, err := c.fromYAML(content, pointer, &schema.Required.Strings, pol)
+			if err != nil {
+				return nil, err
+			}
+
+			// properties declared by the root annotation override the generated ones
+			declared := schema.Properties
+			schema.Properties = docSchema.Properties
+			for name, prop := range declared.All() {
+				if schema.Properties == nil {
+					schema.Properties = NewProperties()
+				}
+				schema.Properties.Set(name, prop)
+			}
+
+			if schema.AdditionalProperties == nil {
+				schema.AdditionalProperties = c.mappingAdditionalProperties(content, pol)
+			}
+
+		case yaml.SequenceNode:
+			// e.g. a list of tenants
+			if schema.Items == nil && schema.PrefixItems == nil {
+				if err := c.arrayItems(schema, content, pointer, "", pol); err != nil {
+					return nil, err
+				}
+				FixRequiredProperties(schema)
+			}
 
-		schema.Schema = "http://json-schema.org/draft-07/schema#"
-		schema.Properties = FromYAML(
-			valuesPath,
-			node.Content[0],
-			&schema.Required.Strings,
-		).Properties
+		case yaml.ScalarNode:
+			if schema.Default == nil && content.Tag != nullTag {
+				schema.Default = castNodeValueByType(content.Value, contentType)
+			}
+		}
 
-		schema.AdditionalProperties = new(bool)
+		// local references point to keys generated anywhere in the document
+		if err := c.resolveLocalRefs(schema); err != nil {
+			return nil, err
+		}
+		if err := c.useLibrary(schema); err != nil {
+			return nil, err
+		}
 
 	case yaml.MappingNode:
-		for i := 0; i < len(node.Content); i += 2 {
-			keyNode := node.Content[i]
-			valueNode := node.Content[i+1]
+		if parentRequiredProperties == nil {
+			// a mapping converted on its own collects its required keys itself
+			parentRequiredProperties = &schema.Required.Strings
+		}
 
-			if valueNode.Kind == yaml.AliasNode {
-				valueNode = valueNode.Alias
+		pairs, err := c.mappingPairs(node, pointer)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i < len(pairs); i += 2 {
+			keyNode := pairs[i]
+			keyPointer := jsonpointer.Append(pointer, "properties", keyNode.Value)
+			valueNode, err := c.expandAlias(pairs[i+1], keyPointer, keyNode.Value)
+			if err != nil {
+				return nil, err
+			}
+			if valueNode == nil {
+				continue
 			}
 
 			comment := keyNode.HeadComment
@@ -51,37 +513,85 @@ func FromYAML(
 					comment = leadingCommentsRemover.ReplaceAllString(comment, "")
 				}*/
 
-			keyNodeSchema, description, err := GetSchemaFromComment(comment)
+			keyNodeSchema, description, err := c.annotation(keyNode, comment, keyPointer, GetSchemaFromComment)
 			if err != nil {
-				log.Fatalf("Error while parsing comment of key %s: %v", keyNode.Value, err)
+				return nil, err
+			}
+
+			// Skipped keys are omitted from the schema
+			if keyNodeSchema.Skip {
+				continue
+			}
+
+			internal, err := boolAnnotation(&keyNodeSchema, InternalAnnotation)
+			if err == nil && internal {
+				keyNodeSchema.ReadOnly = true
+			}
+			passthrough, passthroughErr := boolAnnotation(&keyNodeSchema, PassthroughAnnotation)
+			for _, err := range []error{err, passthroughErr} {
+				if err == nil {
+					continue
+				}
+				if err := c.report(c.annotationErrorAt(keyNode, keyPointer, ErrInvalidAnnotation, err)); err != nil {
+					return nil, err
+				}
 			}
 
-			if keyNodeSchema.Ref != "" || len(keyNodeSchema.PatternProperties) > 0 {
-				// Handle $ref in main schema and pattern properties
-				handleSchemaRefs(&keyNodeSchema, valuesPath)
+			// Pass-through keys accept any value: only the title, description,
+			// readOnly and custom annotations are emitted and the children are not walked
+			if passthrough {
+				passthroughSchema := NewSchema("")
+				passthroughSchema.Title = cmp.Or(keyNodeSchema.Title, keyNode.Value)
+				passthroughSchema.Description = cmp.Or(keyNodeSchema.Description, description)
+				passthroughSchema.ReadOnly = keyNodeSchema.ReadOnly
+				passthroughSchema.CustomAnnotations = keyNodeSchema.CustomAnnotations
+				delete(passthroughSchema.CustomAnnotations, PassthroughAnnotation)
+
+				if keyNodeSchema.Required.Bool && !slices.Contains(*parentRequiredProperties, keyNode.Value) {
+					*parentRequiredProperties = append(*parentRequiredProperties, keyNode.Value)
+				}
+				if schema.Properties == nil {
+					schema.Properties = NewProperties()
+				}
+				schema.Properties.Set(keyNode.Value, passthroughSchema)
+				continue
 			}
 
-			if keyNodeSchema.HasData {
-				if err := keyNodeSchema.Validate(); err != nil {
-					log.Fatalf(
-						"Error while validating jsonschema of key %s: %v",
-						keyNode.Value,
-						err,
-					)
+			// A pinned type keeps the inference of a key without annotation
+			pinned, err := pinnedType(&keyNodeSchema)
+			if err != nil {
+				if err := c.report(c.annotationErrorAt(keyNode, keyPointer, ErrInvalidAnnotation, err)); err != nil {
+					return nil, err
 				}
-			} else {
-				nodeType, err := typeFromTag(valueNode.Tag)
+			}
+			if pinned != nil && schemasEqual(&keyNodeSchema, &Schema{}) {
+				keyNodeSchema.HasData = false
+			}
+
+			if !keyNodeSchema.HasData {
+				nodeType, err := c.inferType(valueNode)
 				if err != nil {
-					log.Fatal(err)
+					if err := c.report(c.errorAt(valueNode, keyPointer, keyNode.Value, ErrUnsupportedTag, err)); err != nil {
+						return nil, err
+					}
 				}
 				keyNodeSchema.Type = nodeType
 			}
+			if pinned != nil {
+				keyNodeSchema.Type = pinned
+			}
+
+			childPolicy, err := c.childPolicy(keyNode, keyPointer, &keyNodeSchema, pol)
+			if err != nil {
+				return nil, err
+			}
 
 			// only validate or default if $ref is not set
 			if keyNodeSchema.Ref == "" {
 
 				// Add key to required array of parent
-				if keyNodeSchema.Required.Bool || (len(keyNodeSchema.Required.Strings) == 0 && !keyNodeSchema.HasData) {
+				if keyNodeSchema.Required.Bool ||
+					(pol.requiredByDefault && len(keyNodeSchema.Required.Strings) == 0 && !keyNodeSchema.HasData) {
 					if !slices.Contains(*parentRequiredProperties, keyNode.Value) {
 						*parentRequiredProperties = append(*parentRequiredProperties, keyNode.Value)
 					}
@@ -89,7 +599,7 @@ func FromYAML(
 
 				if valueNode.Kind == yaml.MappingNode &&
 					(!keyNodeSchema.HasData || keyNodeSchema.AdditionalProperties == nil) {
-					keyNodeSchema.AdditionalProperties = new(bool)
+					keyNodeSchema.AdditionalProperties = c.mappingAdditionalProperties(valueNode, childPolicy)
 				}
 
 				// If no title was set, use the key value
@@ -102,35 +612,59 @@ func FromYAML(
 					keyNodeSchema.Description = description
 				}
 
-				// If no default value was set, use the values node value as default
-				if keyNodeSchema.Default == nil && valueNode.Kind == yaml.ScalarNode {
-					keyNodeSchema.Default = castNodeValueByType(valueNode.Value, keyNodeSchema.Type)
+				// If no default value was set, use the values node value as default,
+				// a null placeholder has none
+				if keyNodeSchema.Default == nil && valueNode.Kind == yaml.ScalarNode && valueNode.Tag != nullTag {
+					fieldType := keyNodeSchema.Type
+					if fieldType.IsEmpty() {
+						// e.g. an enum annotation without type: keep the type written in the values
+						fieldType, _ = typeFromTag(valueNode.Tag)
+					}
+					keyNodeSchema.Default = castNodeValueByType(valueNode.Value, fieldType)
 				}
 
-				// If the value is another map and no properties are set, get them from default values
-				if valueNode.Kind == yaml.MappingNode && keyNodeSchema.Properties == nil {
-					// Initialize properties map if needed
-					if keyNodeSchema.Properties == nil {
-						keyNodeSchema.Properties = make(map[string]*Schema)
+				// The default value must be one of the allowed values
+				if keyNodeSchema.Default != nil && !keyNodeSchema.AllowsValue(keyNodeSchema.Default) {
+					err := c.report(c.errorAt(valueNode, keyPointer, keyNode.Value, ErrInvalidDefault,
+						fmt.Errorf("%v is not allowed by the enum or const annotation", keyNodeSchema.Default)))
+					if err != nil {
+						return nil, err
 					}
+				}
 
-					generatedProperties := FromYAML(
-						valuesPath,
-						valueNode,
-						&keyNodeSchema.Required.Strings,
-					).Properties
+				if c.opts.AnchorDefs && valueNode.Kind == yaml.MappingNode && valueNode.Anchor != "" && !keyNodeSchema.HasData {
+					// An anchored mapping is described once and referenced by each key using it
+					ref, err := c.anchorRef(valueNode, childPolicy)
+					if err != nil {
+						return nil, err
+					}
+					keyNodeSchema = Schema{
+						Ref:         ref,
+						Title:       keyNodeSchema.Title,
+						Description: keyNodeSchema.Description,
+					}
+				} else if valueNode.Kind == yaml.MappingNode && keyNodeSchema.Properties == nil {
+					// If the value is another map and no properties are set, get them from default values
+					keyNodeSchema.Properties = NewProperties()
 
-					// Process each property
-					for i := 0; i < len(valueNode.Content); i += 2 {
-						propKeyNode := valueNode.Content[i]
-						// propValueNode := valueNode.Content[i+1]
+					generated, err := c.fromYAML(valueNode, keyPointer, &keyNodeSchema.Required.Strings, childPolicy)
+					if err != nil {
+						return nil, err
+					}
 
+					// Process each property, skipped keys have no schema
+					for propName, propSchema := range generated.Properties.All() {
 						// Check if this specific property matches any pattern
 						skipProperty := false
 						for pattern := range keyNodeSchema.PatternProperties {
-							matched, err := regexp.MatchString(pattern, propKeyNode.Value)
+							matched, err := regexp.MatchString(pattern, propName)
 							if err != nil {
-								log.Fatalf("Invalid pattern '%s' in patternProperties: %v", pattern, err)
+								err = c.report(c.annotationErrorAt(keyNode, keyPointer, ErrInvalidSchema,
+									fmt.Errorf("invalid pattern '%s' in patternProperties: %w", pattern, err)))
+								if err != nil {
+									return nil, err
+								}
+								continue
 							}
 							if matched {
 								skipProperty = true
@@ -140,33 +674,15 @@ func FromYAML(
 
 						// Only add schema for non-skipped properties
 						if !skipProperty {
-							keyNodeSchema.Properties[propKeyNode.Value] = generatedProperties[propKeyNode.Value]
+							keyNodeSchema.Properties.Set(propName, propSchema)
 						}
 					}
-				} else if valueNode.Kind == yaml.SequenceNode && keyNodeSchema.Items == nil {
+				} else if valueNode.Kind == yaml.SequenceNode && keyNodeSchema.Items == nil &&
+					keyNodeSchema.PrefixItems == nil {
 					// If the value is a sequence, but no items are predefined
-					seqSchema := NewSchema("")
-
-					for _, itemNode := range valueNode.Content {
-						if itemNode.Kind == yaml.ScalarNode {
-							itemNodeType, err := typeFromTag(itemNode.Tag)
-							if err != nil {
-								log.Fatal(err)
-							}
-							seqSchema.AnyOf = append(seqSchema.AnyOf, NewSchema(itemNodeType[0]))
-						} else {
-							itemRequiredProperties := []string{}
-							itemSchema := FromYAML(valuesPath, itemNode, &itemRequiredProperties)
-							itemSchema.Required.Strings = append(itemSchema.Required.Strings, itemRequiredProperties...)
-
-							if itemNode.Kind == yaml.MappingNode && (!itemSchema.HasData || itemSchema.AdditionalProperties == nil) {
-								itemSchema.AdditionalProperties = new(bool)
-							}
-
-							seqSchema.AnyOf = append(seqSchema.AnyOf, itemSchema)
-						}
+					if err := c.arrayItems(&keyNodeSchema, valueNode, keyPointer, keyNode.Value, childPolicy); err != nil {
+						return nil, err
 					}
-					keyNodeSchema.Items = seqSchema
 
 					// Because the `required` field isn't valid jsonschema (but just a helper boolean)
 					// we must convert them to valid requiredProperties fields
@@ -175,11 +691,11 @@ func FromYAML(
 			}
 
 			if schema.Properties == nil {
-				schema.Properties = make(map[string]*Schema)
+				schema.Properties = NewProperties()
 			}
-			schema.Properties[keyNode.Value] = &keyNodeSchema
+			schema.Properties.Set(keyNode.Value, &keyNodeSchema)
 		}
 	}
 
-	return schema
+	return schema, nil
 }
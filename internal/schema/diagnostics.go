@@ -0,0 +1,164 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Error kinds reported by diagnostics. Use errors.Is to match them.
+var (
+	ErrInvalidDocument   = errors.New("invalid yaml document")
+	ErrInvalidAnnotation = errors.New("invalid schema annotation")
+	ErrInvalidSchema     = errors.New("invalid schema")
+	ErrUnsupportedTag    = errors.New("unsupported yaml tag")
+	ErrInvalidRef        = errors.New("unable to resolve $ref")
+	ErrUnknownKeyword    = errors.New("unknown keyword")
+	ErrInvalidDefault    = errors.New("invalid default value")
+	ErrLint              = errors.New("lint")
+	ErrTooManyErrors     = errors.New("too many errors")
+)
+
+// Severity tells how serious a diagnostic is
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Diagnostic reports a problem found while building the schema, together with
+// its position in the values file and in the generated schema
+type Diagnostic struct {
+	Severity Severity
+	// Kind is one of the Err* sentinel errors
+	Kind error
+	// File is the path of the values file
+	File string
+	// Line and Column locate the problem in the values file (1-based, 0 if unknown)
+	Line   int
+	Column int
+	// Pointer is the JSON pointer of the property being built
+	Pointer string
+	// Key is the values key being processed
+	Key string
+	// Err is the underlying cause
+	Err error
+}
+
+// Position returns the file:line:column location of the diagnostic
+func (d *Diagnostic) Position() string {
+	pos := []string{}
+	if d.File != "" {
+		pos = append(pos, d.File)
+	}
+	if d.Line > 0 {
+		pos = append(pos, fmt.Sprint(d.Line))
+		if d.Column > 0 {
+			pos = append(pos, fmt.Sprint(d.Column))
+		}
+	}
+	return strings.Join(pos, ":")
+}
+
+func (d *Diagnostic) Error() string {
+	msg := fmt.Sprintf("%s: %v", d.Severity, d.Kind)
+	if d.Pointer != "" {
+		msg = fmt.Sprintf("%s at %s", msg, d.Pointer)
+	}
+	msg = fmt.Sprintf("%s: %v", msg, d.Err)
+	if pos := d.Position(); pos != "" {
+		msg = pos + ": " + msg
+	}
+	return msg
+}
+
+func (d *Diagnostic) Unwrap() []error {
+	return []error{d.Kind, d.Err}
+}
+
+// As lets errors.As match the diagnostics about a key as a *KeyError
+func (d *Diagnostic) As(target any) bool {
+	keyErr, ok := target.(**KeyError)
+	if !ok || d.Key == "" {
+		return false
+	}
+	*keyErr = &KeyError{Key: d.Key, Kind: d.Kind, Err: d.Err, Diagnostic: d}
+	return true
+}
+
+// KeyError reports a failure that occurred while building the schema of a key.
+// It is a view of the Diagnostic about the key, which carries its position.
+type KeyError struct {
+	// Key is the values key being processed
+	Key string
+	// Kind is one of the Err* sentinel errors
+	Kind error
+	// Err is the underlying cause
+	Err error
+	// Diagnostic is the full diagnostic
+	Diagnostic *Diagnostic
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("%v of key %s: %v", e.Kind, e.Key, e.Err)
+}
+
+func (e *KeyError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Diagnostics is the list of problems found in a run. It implements error
+// so that all problems can be returned at once.
+type Diagnostics []*Diagnostic
+
+func (d Diagnostics) Error() string {
+	lines := make([]string, 0, len(d))
+	for _, diag := range d {
+		lines = append(lines, diag.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (d Diagnostics) Unwrap() []error {
+	errs := make([]error, 0, len(d))
+	for _, diag := range d {
+		errs = append(errs, diag)
+	}
+	return errs
+}
+
+// HasErrors reports whether any diagnostic has error severity
+func (d Diagnostics) HasErrors() bool {
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// AnnotationError reports a problem located inside a comment
+type AnnotationError struct {
+	// Line is the 1-based line of the comment the problem was found at
+	Line int
+	Err  error
+}
+
+func (e *AnnotationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AnnotationError) Unwrap() error {
+	return e.Err
+}
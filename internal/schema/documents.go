@@ -0,0 +1,158 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DocumentsMode selects how the documents of a multi-document YAML stream are converted
+type DocumentsMode string
+
+// Supported documents modes
+const (
+	// DocumentsSplit generates one schema per document
+	DocumentsSplit DocumentsMode = "split"
+	// DocumentsOneOf generates a single schema accepting the values of exactly one of the documents
+	DocumentsOneOf DocumentsMode = "oneOf"
+	// DocumentsMerge generates a single schema, the union of the schemas of the documents
+	DocumentsMerge DocumentsMode = "merge"
+)
+
+// ParseDocumentsMode returns the documents mode matching the given name, DocumentsSplit when empty
+func ParseDocumentsMode(name string) (DocumentsMode, error) {
+	switch mode := DocumentsMode(name); mode {
+	case "":
+		return DocumentsSplit, nil
+	case DocumentsSplit, DocumentsOneOf, DocumentsMerge:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unsupported documents mode %q, expected %q, %q or %q",
+		name, DocumentsSplit, DocumentsOneOf, DocumentsMerge)
+}
+
+// FromYAMLDocuments creates the JSON Schemas of the given YAML document nodes, see FromYAML.
+// In DocumentsSplit mode one schema is returned per document, otherwise a single schema
+// combining them all.
+func FromYAMLDocuments(ctx context.Context, documents []*yaml.Node, opts Options) ([]*Schema, Diagnostics, error) {
+	c := &converter{ctx: ctx, opts: opts}
+	split := opts.Documents == "" || opts.Documents == DocumentsSplit
+
+	if opts.DefsFile != "" {
+		library, err := c.loadLibrary(opts.DefsFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+		}
+		c.fileLibrary = library
+	}
+	c.library = maps.Clone(c.fileLibrary)
+	c.defNames = slices.Collect(maps.Keys(c.library))
+
+	schemas := []*Schema{}
+	var err error
+	for _, document := range documents {
+		if split {
+			// each schema holds the definitions of its own anchors and references
+			c.defs, c.anchorNames, c.refNames, c.refSites = nil, nil, nil, nil
+			c.library = maps.Clone(c.fileLibrary)
+			c.defNames = slices.Collect(maps.Keys(c.library))
+		}
+
+		var schema *Schema
+		schema, err = c.fromYAML(document, "", nil, newPolicy(opts))
+		if schema != nil {
+			if split {
+				c.attachDefs(schema)
+			}
+			schemas = append(schemas, schema)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if !split && len(schemas) > 0 {
+		combined := schemas[0]
+		switch {
+		case len(schemas) == 1:
+		case opts.Documents == DocumentsOneOf:
+			combined = oneOfDocuments(schemas)
+		default:
+			combined = mergeDocuments(schemas)
+		}
+		c.attachDefs(combined)
+		schemas = []*Schema{combined}
+	}
+
+	for _, schema := range schemas {
+		c.finish(schema)
+	}
+
+	if errors.Is(err, ErrTooManyErrors) {
+		c.report(&Diagnostic{
+			Severity: SeverityError,
+			Kind:     ErrTooManyErrors,
+			File:     opts.ValuesPath,
+			Err:      fmt.Errorf("stopping after %d errors", c.errors),
+		})
+		return schemas, c.diags, nil
+	}
+	if err != nil {
+		return nil, c.diags, err
+	}
+	return schemas, c.diags, nil
+}
+
+// oneOfDocuments returns the schema accepting the values of exactly one of the given document schemas.
+// Documents accepting the same values are listed once and the definitions are moved to the combined schema.
+func oneOfDocuments(documents []*Schema) *Schema {
+	combined := &Schema{}
+	for _, document := range documents {
+		moveDocumentKeywords(combined, document)
+
+		// documents differing only by annotations, e.g. defaults, would all match the same values
+		if !slices.ContainsFunc(combined.OneOf, func(s *Schema) bool { return constraintsEqual(s, document) }) {
+			combined.OneOf = append(combined.OneOf, document)
+		}
+	}
+	return combined
+}
+
+// mergeDocuments returns the schema accepting the values of any of the given document schemas,
+// see mergeSchemas. The definitions are moved to the merged schema, which also holds the $schema
+// when documents of different kinds are combined with anyOf.
+func mergeDocuments(documents []*Schema) *Schema {
+	root := &Schema{}
+	var merged *Schema
+	for _, document := range documents {
+		moveDocumentKeywords(root, document)
+		merged = mergeSchemas(merged, document)
+	}
+	merged.Schema, merged.Defs, merged.Definitions = root.Schema, root.Defs, root.Definitions
+	return merged
+}
+
+// moveDocumentKeywords moves the $schema and the definitions of a document schema to the
+// schema combining it with other documents, the definitions of later documents taking precedence
+func moveDocumentKeywords(combined, document *Schema) {
+	if combined.Schema == "" {
+		combined.Schema = document.Schema
+	}
+	for name, def := range document.Defs {
+		if combined.Defs == nil {
+			combined.Defs = map[string]*Schema{}
+		}
+		combined.Defs[name] = def
+	}
+	for name, def := range document.Definitions {
+		if combined.Definitions == nil {
+			combined.Definitions = map[string]*Schema{}
+		}
+		combined.Definitions[name] = def
+	}
+	document.Schema, document.Defs, document.Definitions = "", nil, nil
+}
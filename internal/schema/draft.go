@@ -0,0 +1,184 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// Draft identifies the JSON Schema specification version of the generated schema
+type Draft string
+
+// Supported JSON Schema drafts
+const (
+	Draft7    Draft = "draft-07"
+	Draft2019 Draft = "2019-09"
+	Draft2020 Draft = "2020-12"
+
+	// DefaultDraft is used when no draft is selected
+	DefaultDraft = Draft7
+)
+
+// ParseDraft returns the draft matching the given name.
+// Both short ("7", "2020-12") and full ("draft-07", "draft/2020-12") names are accepted,
+// as well as the draft $schema URI.
+func ParseDraft(name string) (Draft, error) {
+	switch strings.TrimSuffix(strings.TrimSpace(name), "#") {
+	case "", "7", "07", "draft-07", "draft7", Draft7.URI(), strings.TrimSuffix(Draft7.URI(), "#"):
+		return Draft7, nil
+	case "2019-09", "draft/2019-09", "2019", Draft2019.URI():
+		return Draft2019, nil
+	case "2020-12", "draft/2020-12", "2020", Draft2020.URI():
+		return Draft2020, nil
+	}
+	return "", fmt.Errorf("unsupported JSON Schema draft: %s", name)
+}
+
+// orDefault returns DefaultDraft when d is not set
+func (d Draft) orDefault() Draft {
+	if d == "" {
+		return DefaultDraft
+	}
+	return d
+}
+
+// URI returns the $schema URI of the draft
+func (d Draft) URI() string {
+	switch d.orDefault() {
+	case Draft2019:
+		return "https://json-schema.org/draft/2019-09/schema"
+	case Draft2020:
+		return "https://json-schema.org/draft/2020-12/schema"
+	}
+	return "http://json-schema.org/draft-07/schema#"
+}
+
+// defsKeyword returns the keyword holding reusable definitions
+func (d Draft) defsKeyword() string {
+	if d.orDefault() == Draft7 {
+		return "definitions"
+	}
+	return "$defs"
+}
+
+var (
+	metaSchemasMu sync.Mutex
+	metaSchemas   = map[Draft]*jsonschema.Schema{}
+)
+
+// metaSchema returns the compiled official meta-schema of the draft
+func (d Draft) metaSchema() (*jsonschema.Schema, error) {
+	metaSchemasMu.Lock()
+	defer metaSchemasMu.Unlock()
+
+	d = d.orDefault()
+	if meta, ok := metaSchemas[d]; ok {
+		return meta, nil
+	}
+
+	meta, err := jsonschema.NewCompiler().Compile(strings.TrimSuffix(d.URI(), "#"))
+	if err != nil {
+		return nil, err
+	}
+	metaSchemas[d] = meta
+	return meta, nil
+}
+
+// translateKeywords rewrites the keywords of a marshaled schema object to the vocabulary of the draft.
+// The Schema struct models the 2020-12 vocabulary and also accepts "definitions".
+func (d Draft) translateKeywords(data map[string]json.RawMessage) error {
+	d = d.orDefault()
+
+	// definitions and $defs are merged under the draft keyword
+	defsKey := d.defsKeyword()
+	for _, key := range []string{"definitions", "$defs"} {
+		if key == defsKey {
+			continue
+		}
+		if err := mergeRawObjects(data, key, defsKey); err != nil {
+			return err
+		}
+	}
+
+	if ref, ok := data["$ref"]; ok {
+		var s string
+		if err := json.Unmarshal(ref, &s); err != nil {
+			return err
+		}
+		for _, prefix := range []string{"#/definitions/", "#/$defs/"} {
+			if name, found := strings.CutPrefix(s, prefix); found {
+				s = "#/" + defsKey + "/" + name
+			}
+		}
+		data["$ref"], _ = json.Marshal(s)
+	}
+
+	if d == Draft2020 {
+		return nil
+	}
+
+	// before 2020-12 tuple validation uses the array form of items,
+	// the schema of the remaining items being additionalItems
+	if prefixItems, ok := data["prefixItems"]; ok {
+		if items, ok := data["items"]; ok {
+			data["additionalItems"] = items
+		}
+		data["items"] = prefixItems
+		delete(data, "prefixItems")
+	}
+
+	// draft-07 merges dependentRequired and dependentSchemas into dependencies
+	// and declares anchors through a fragment-only $id
+	if d == Draft7 {
+		if anchor, ok := data["$anchor"]; ok {
+			var name string
+			if err := json.Unmarshal(anchor, &name); err != nil {
+				return err
+			}
+			if _, ok := data["$id"]; !ok {
+				data["$id"], _ = json.Marshal("#" + name)
+			}
+			delete(data, "$anchor")
+		}
+
+		for _, key := range []string{"dependentRequired", "dependentSchemas"} {
+			if err := mergeRawObjects(data, key, "dependencies"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// mergeRawObjects moves the members of the object at key from into the object at key to
+func mergeRawObjects(data map[string]json.RawMessage, from, to string) error {
+	src, ok := data[from]
+	if !ok {
+		return nil
+	}
+	delete(data, from)
+
+	merged := map[string]json.RawMessage{}
+	if dst, ok := data[to]; ok {
+		if err := json.Unmarshal(dst, &merged); err != nil {
+			return err
+		}
+	}
+	members := map[string]json.RawMessage{}
+	if err := json.Unmarshal(src, &members); err != nil {
+		return err
+	}
+	maps.Copy(merged, members)
+
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	data[to] = raw
+	return nil
+}
@@ -0,0 +1,70 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnknownKeyword is a key of a @schema block that is neither a known keyword
+// nor a custom annotation, most likely a typo
+type UnknownKeyword struct {
+	Name string
+	// Line and Column locate the key: inside the annotation block
+	// once returned by GetSchemaFromComment, 0 when unknown
+	Line   int
+	Column int
+	// Suggestion is the closest known keyword, if any is close enough
+	Suggestion string
+}
+
+func (k UnknownKeyword) String() string {
+	if k.Suggestion != "" {
+		return fmt.Sprintf("%q (did you mean %q?)", k.Name, k.Suggestion)
+	}
+	return fmt.Sprintf("%q", k.Name)
+}
+
+// suggestKeyword returns the known keyword closest to name,
+// or an empty string when none is close enough to be a likely typo
+func suggestKeyword(name string, known []string) string {
+	best, bestDistance := "", -1
+	for _, k := range known {
+		d := editDistance(strings.ToLower(name), strings.ToLower(k))
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+
+	if bestDistance < 0 || bestDistance > max(1, len(name)/4) {
+		return ""
+	}
+	return best
+}
+
+// editDistance returns the edit distance between a and b, counting insertions,
+// deletions, substitutions and transpositions of adjacent characters
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
@@ -0,0 +1,157 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/krateoplatformops/yaml-to-jsonschema/internal/jsonpointer"
+)
+
+// UseAnnotation references a definition of the library by name, e.g. x-use: port
+// is a shorthand for $ref: "#/$defs/port"
+const UseAnnotation = "x-use"
+
+// loadLibrary returns the definitions declared by the file at path: the members of its
+// $defs or definitions keyword or, when it has neither, its own members.
+// The references of the definitions to other files are resolved relative to path and inlined.
+func (c *converter) loadLibrary(path string) (map[string]*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := decodeSchemaFile(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	members, ok := obj.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected an object of definitions", path)
+	}
+	for _, key := range []string{"$defs", "definitions"} {
+		if defs, ok := members[key].(map[string]any); ok {
+			members = defs
+			break
+		}
+	}
+
+	library := map[string]*Schema{}
+	for name, member := range members {
+		raw, err := json.Marshal(member)
+		if err != nil {
+			return nil, err
+		}
+		var def Schema
+		if err := json.Unmarshal(raw, &def); err != nil {
+			return nil, fmt.Errorf("%s: definition %s: %w", path, name, err)
+		}
+
+		r := refResolver{valuesPath: path, documents: map[string]any{}}
+		if err := r.resolve(&def, ""); err != nil {
+			return nil, fmt.Errorf("%s: definition %s: %w", path, name, err)
+		}
+		def.SetDraft(c.opts.Draft)
+		if err := def.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: definition %s: %w", path, name, err)
+		}
+		library[name] = &def
+	}
+	return library, nil
+}
+
+// defRefName returns the name of the definition referenced by ref, if ref points into
+// the definitions, e.g. #/$defs/port or #/definitions/port/properties/number give port
+func defRefName(ref string) (string, bool) {
+	for _, prefix := range []string{"#/$defs/", "#/definitions/"} {
+		if name, ok := strings.CutPrefix(ref, prefix); ok {
+			name, _, _ = strings.Cut(name, "/")
+			return jsonpointer.Unescape(name), true
+		}
+	}
+	return "", false
+}
+
+// useAnnotation replaces the UseAnnotation of s with the $ref to the named library definition
+func (c *converter) useAnnotation(s *Schema) error {
+	value, ok := s.CustomAnnotations[UseAnnotation]
+	if !ok {
+		return nil
+	}
+	delete(s.CustomAnnotations, UseAnnotation)
+
+	name, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("%s must be the name of a definition, got %v", UseAnnotation, value)
+	}
+	if _, ok := c.library[name]; !ok {
+		return fmt.Errorf("%s: unknown definition %q, expected one of %v",
+			UseAnnotation, name, slices.Sorted(maps.Keys(c.library)))
+	}
+	if s.Ref != "" {
+		return fmt.Errorf("%s cannot be used with $ref", UseAnnotation)
+	}
+	s.Ref = "#/$defs/" + jsonpointer.Escape(name)
+	return nil
+}
+
+// useLibrary adds the library definitions referenced by document, by the generated
+// definitions and by the definitions added this way to the generated definitions.
+// The definitions declared by the root annotation are already part of document.
+// The references of the annotations to definitions that do not exist are reported
+// where they were found, see recordLocalRefs, and removed.
+func (c *converter) useLibrary(document *Schema) error {
+	unknown := []*Schema{}
+	pending := []*Schema{document}
+	for _, def := range c.defs {
+		pending = append(pending, def)
+	}
+	for len(pending) > 0 {
+		schema := pending[0]
+		pending = pending[1:]
+		schema.walk(func(s *Schema) {
+			name, ok := defRefName(s.Ref)
+			if !ok {
+				return
+			}
+			if _, used := c.defs[name]; used {
+				return
+			}
+			if _, declared := document.Defs[name]; declared {
+				return
+			}
+			if _, declared := document.Definitions[name]; declared {
+				return
+			}
+			def, ok := c.library[name]
+			if !ok {
+				unknown = append(unknown, s)
+				return
+			}
+			c.addDef(name, def)
+			pending = append(pending, def)
+		})
+	}
+
+	reported := map[string]bool{}
+	for _, s := range unknown {
+		site, ok := c.refSites[s.Ref]
+		if !ok {
+			// e.g. a reference of a referenced file, left to the consumers of the schema
+			continue
+		}
+		if !reported[s.Ref] {
+			reported[s.Ref] = true
+			name, _ := defRefName(s.Ref)
+			d := c.annotationErrorAt(site.keyNode, site.pointer, ErrInvalidRef,
+				fmt.Errorf("%s: unknown definition %q", s.Ref, name))
+			if err := c.report(d); err != nil {
+				return err
+			}
+		}
+		s.Ref = ""
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Supported format values according to JSON Schema specification
+const (
+	FormatDateTime       = "date-time"
+	FormatTime           = "time"
+	FormatDate           = "date"
+	FormatDuration       = "duration"
+	FormatEmail          = "email"
+	FormatIDNEmail       = "idn-email"
+	FormatHostname       = "hostname"
+	FormatIDNHostname    = "idn-hostname"
+	FormatIPv4           = "ipv4"
+	FormatIPv6           = "ipv6"
+	FormatUUID           = "uuid"
+	FormatURI            = "uri"
+	FormatURIReference   = "uri-reference"
+	FormatIRI            = "iri"
+	FormatIRIReference   = "iri-reference"
+	FormatURITemplate    = "uri-template"
+	FormatJSONPointer    = "json-pointer"
+	FormatRelJSONPointer = "relative-json-pointer"
+	FormatRegex          = "regex"
+)
+
+var supportedFormats = map[string]bool{
+	FormatDateTime: true, FormatTime: true, FormatDate: true,
+	FormatDuration: true, FormatEmail: true, FormatIDNEmail: true,
+	FormatHostname: true, FormatIDNHostname: true, FormatIPv4: true,
+	FormatIPv6: true, FormatUUID: true, FormatURI: true,
+	FormatURIReference: true, FormatIRI: true, FormatIRIReference: true,
+	FormatURITemplate: true, FormatJSONPointer: true,
+	FormatRelJSONPointer: true, FormatRegex: true,
+}
+
+// LintRule is an opinionated check on an annotation. Unlike Validate failures,
+// lint findings do not make the schema invalid and are reported as warnings.
+type LintRule struct {
+	Name        string
+	Description string
+	check       func(s *Schema) error
+}
+
+// LintRules lists all the lint rules, each one can be disabled by name
+var LintRules = []LintRule{
+	{
+		Name:        "const-with-type",
+		Description: "const and type are used in the same schema",
+		check: func(s *Schema) error {
+			if s.HasConst() && !s.Type.IsEmpty() {
+				return errors.New("cannot use both 'const' and 'type' in the same schema")
+			}
+			return nil
+		},
+	},
+	{
+		Name:        "enum-with-type",
+		Description: "enum and type are used in the same schema",
+		check: func(s *Schema) error {
+			if s.Enum != nil && !s.Type.IsEmpty() {
+				return errors.New("cannot use both 'enum' and 'type' in the same schema")
+			}
+			return nil
+		},
+	},
+	{
+		Name:        "numeric-keyword-type",
+		Description: "numeric constraints are used on a non numeric type",
+		check: func(s *Schema) error {
+			hasNumericConstraints := s.Minimum != nil || s.Maximum != nil ||
+				s.ExclusiveMinimum != nil || s.ExclusiveMaximum != nil ||
+				s.MultipleOf != nil
+			if hasNumericConstraints && !s.Type.IsEmpty() && !s.Type.Matches("number") && !s.Type.Matches("integer") {
+				return fmt.Errorf("numeric constraints can only be used with number or integer types, got %v", s.Type)
+			}
+			return nil
+		},
+	},
+	{
+		Name:        "inclusive-and-exclusive-bound",
+		Description: "both the inclusive and the exclusive form of a bound are used",
+		check: func(s *Schema) error {
+			if s.Minimum != nil && s.ExclusiveMinimum != nil {
+				return errors.New("cannot use both minimum and exclusiveMinimum")
+			}
+			if s.Maximum != nil && s.ExclusiveMaximum != nil {
+				return errors.New("cannot use both maximum and exclusiveMaximum")
+			}
+			return nil
+		},
+	},
+	{
+		Name:        "string-keyword-type",
+		Description: "format or pattern are used on a non string type",
+		check: func(s *Schema) error {
+			if s.Type.IsEmpty() || s.Type.Matches("string") {
+				return nil
+			}
+			if s.Format != "" {
+				return fmt.Errorf("format can only be used with string type, got %v", s.Type)
+			}
+			if s.Pattern != "" {
+				return fmt.Errorf("pattern can only be used with string type, got %v", s.Type)
+			}
+			return nil
+		},
+	},
+	{
+		Name:        "unknown-format",
+		Description: "format is not one of the formats defined by the specification",
+		check: func(s *Schema) error {
+			if s.Format != "" && !supportedFormats[s.Format] {
+				return fmt.Errorf("unsupported format: %s", s.Format)
+			}
+			return nil
+		},
+	},
+	{
+		Name:        "format-with-pattern",
+		Description: "format and pattern are used in the same schema",
+		check: func(s *Schema) error {
+			if s.Format != "" && s.Pattern != "" {
+				return errors.New("cannot use both format and pattern in the same schema")
+			}
+			return nil
+		},
+	},
+	{
+		Name:        "array-keyword-type",
+		Description: "items, minItems or maxItems are used on a non array type",
+		check: func(s *Schema) error {
+			if s.Type.IsEmpty() || s.Type.Matches("array") {
+				return nil
+			}
+			if s.Items != nil {
+				return fmt.Errorf("items can only be used with array type, got %v", s.Type)
+			}
+			if s.MinItems != nil || s.MaxItems != nil {
+				return fmt.Errorf("minItems/maxItems can only be used with array type, got %v", s.Type)
+			}
+			return nil
+		},
+	},
+}
+
+// LintFinding is a lint rule violated by a schema
+type LintFinding struct {
+	Rule string
+	Err  error
+}
+
+func (f LintFinding) Error() string {
+	return fmt.Sprintf("%v [%s]", f.Err, f.Rule)
+}
+
+// LintAll disables every lint rule when listed among the disabled rules
+const LintAll = "all"
+
+// Lint runs the enabled lint rules on s and its nested schemas.
+// Rules listed in disabled are skipped, LintAll skips them all.
+func (s *Schema) Lint(disabled []string) []LintFinding {
+	findings := []LintFinding{}
+	if slices.Contains(disabled, LintAll) {
+		return findings
+	}
+	s.walk(func(v *Schema) {
+		for _, rule := range LintRules {
+			if slices.Contains(disabled, rule.Name) {
+				continue
+			}
+			if err := rule.check(v); err != nil {
+				findings = append(findings, LintFinding{Rule: rule.Name, Err: err})
+			}
+		}
+	})
+	return findings
+}
+
+// CheckLintRules returns an error if names contains an unknown rule name
+func CheckLintRules(names []string) error {
+	for _, name := range names {
+		known := name == LintAll || slices.ContainsFunc(LintRules, func(r LintRule) bool { return r.Name == name })
+		if !known {
+			return fmt.Errorf("unknown lint rule: %s", name)
+		}
+	}
+	return nil
+}
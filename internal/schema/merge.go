@@ -0,0 +1,228 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+)
+
+// scalarTypes lists the JSON types of scalar values
+var scalarTypes = []string{"null", "boolean", "integer", "number", "string"}
+
+// mergeSchemas returns a schema accepting the values of both a and b.
+// Schemas differing only by annotations such as the default value are considered the same.
+// Object schemas are merged property by property, a key being required only when both require it,
+// array schemas by merging their items and scalar types are widened (e.g. integer and number to number).
+// A schema accepting any value absorbs the other one, other different schemas are combined with anyOf.
+func mergeSchemas(a, b *Schema) *Schema {
+	if a == nil {
+		return b
+	}
+	if b == nil || schemasEqual(withoutAnnotations(a), withoutAnnotations(b)) {
+		// e.g. the same key with different defaults
+		return a
+	}
+	for _, s := range []*Schema{a, b} {
+		if schemasEqual(withoutAnnotations(s), &Schema{}) {
+			// e.g. an open null placeholder already accepts any value
+			return s
+		}
+	}
+
+	if isUnionSchema(a) {
+		branches := slices.Clone(a.AnyOf)
+		for i, branch := range branches {
+			if merged := mergeShapes(branch, b); merged != nil {
+				branches[i] = merged
+				return &Schema{AnyOf: branches}
+			}
+		}
+		if !slices.ContainsFunc(branches, func(s *Schema) bool { return schemasEqual(s, b) }) {
+			branches = append(branches, b)
+		}
+		return &Schema{AnyOf: branches}
+	}
+
+	if merged := mergeShapes(a, b); merged != nil {
+		return merged
+	}
+	return &Schema{AnyOf: []*Schema{a, b}}
+}
+
+// mergeShapes merges two objects, two arrays or two scalar schemas.
+// It returns nil when a and b describe different kinds of values.
+func mergeShapes(a, b *Schema) *Schema {
+	if schemasEqual(withoutAnnotations(a), withoutAnnotations(b)) {
+		return a
+	}
+
+	switch {
+	case isObjectSchema(a) && isObjectSchema(b):
+		merged := *a
+		merged.Properties = NewProperties()
+		for name, prop := range a.Properties.All() {
+			if other, ok := b.Properties.Get(name); ok {
+				prop = mergeSchemas(prop, other)
+			}
+			merged.Properties.Set(name, prop)
+		}
+		for name, prop := range b.Properties.All() {
+			if _, ok := a.Properties.Get(name); !ok {
+				merged.Properties.Set(name, prop)
+			}
+		}
+
+		merged.Required.Strings = []string{}
+		for _, name := range a.Required.Strings {
+			if slices.Contains(b.Required.Strings, name) {
+				merged.Required.Strings = append(merged.Required.Strings, name)
+			}
+		}
+
+		// unknown keys are accepted unless both reject them the same way
+		if !jsonEqual(a.AdditionalProperties, b.AdditionalProperties) {
+			merged.AdditionalProperties = nil
+		}
+
+		merged.Defs = mergeDefs(a.Defs, b.Defs)
+		merged.Definitions = mergeDefs(a.Definitions, b.Definitions)
+		return &merged
+
+	case isArraySchema(a) && isArraySchema(b):
+		merged := *a
+		merged.Items = nil
+		if items := mergeSchemas(itemsSchema(a), itemsSchema(b)); items != nil {
+			merged.Items = items
+		}
+		return &merged
+
+	case isScalarSchema(a) && isScalarSchema(b):
+		merged := *a
+		merged.Type = slices.Clone(a.Type)
+		for _, t := range b.Type {
+			if !slices.Contains(merged.Type, t) {
+				merged.Type = append(merged.Type, t)
+			}
+		}
+		// integers are numbers
+		if slices.Contains(merged.Type, "number") {
+			merged.Type = slices.DeleteFunc(merged.Type, func(t string) bool { return t == "integer" })
+		}
+		if len(merged.Type) > 1 {
+			slices.SortFunc(merged.Type, func(x, y string) int {
+				return slices.Index(scalarTypes, x) - slices.Index(scalarTypes, y)
+			})
+		}
+		return &merged
+	}
+	return nil
+}
+
+// mergeDefs returns the definitions of a and b, a taking precedence
+func mergeDefs(a, b map[string]*Schema) map[string]*Schema {
+	if len(b) == 0 {
+		return a
+	}
+	result := map[string]*Schema{}
+	for name, def := range b {
+		result[name] = def
+	}
+	for name, def := range a {
+		result[name] = def
+	}
+	return result
+}
+
+// withoutAnnotations returns a copy of s without the keywords that do not constrain the values
+func withoutAnnotations(s *Schema) *Schema {
+	result := *s
+	result.Title, result.Description, result.Default, result.Examples = "", "", nil, nil
+	return &result
+}
+
+// constraintsEqual reports whether a and b accept the same values, ignoring the annotations at any depth
+func constraintsEqual(a, b *Schema) bool {
+	return schemasEqual(withoutAnnotationsDeep(a), withoutAnnotationsDeep(b))
+}
+
+// withoutAnnotationsDeep returns a copy of s and its nested schemas without annotations
+func withoutAnnotationsDeep(s *Schema) *Schema {
+	if s == nil {
+		return nil
+	}
+	result := withoutAnnotations(s)
+
+	if s.Properties != nil {
+		result.Properties = NewProperties()
+		for name, prop := range s.Properties.All() {
+			result.Properties.Set(name, withoutAnnotationsDeep(prop))
+		}
+	}
+	for _, m := range []*map[string]*Schema{
+		&result.PatternProperties, &result.Defs, &result.Definitions, &result.DependentSchemas,
+	} {
+		stripped := make(map[string]*Schema, len(*m))
+		for name, v := range *m {
+			stripped[name] = withoutAnnotationsDeep(v)
+		}
+		*m = stripped
+	}
+	for _, l := range []*[]*Schema{&result.PrefixItems, &result.AllOf, &result.AnyOf, &result.OneOf} {
+		stripped := make([]*Schema, 0, len(*l))
+		for _, v := range *l {
+			stripped = append(stripped, withoutAnnotationsDeep(v))
+		}
+		*l = stripped
+	}
+	for _, p := range []**Schema{
+		&result.Contains, &result.PropertyNames, &result.If, &result.Then, &result.Else, &result.Not,
+	} {
+		*p = withoutAnnotationsDeep(*p)
+	}
+	for _, v := range []*SchemaOrBool{&result.Items, &result.AdditionalProperties, &result.UnevaluatedProperties, &result.UnevaluatedItems} {
+		if subSchema, ok := (*v).(*Schema); ok {
+			*v = withoutAnnotationsDeep(subSchema)
+		}
+	}
+	return result
+}
+
+// isObjectSchema reports whether s only describes objects by their properties
+func isObjectSchema(s *Schema) bool {
+	return slices.Equal(s.Type, StringOrArrayOfString{"object"}) && s.Ref == "" &&
+		len(s.AnyOf) == 0 && len(s.OneOf) == 0 && len(s.AllOf) == 0
+}
+
+// isArraySchema reports whether s only describes arrays by their items
+func isArraySchema(s *Schema) bool {
+	other := *withoutAnnotations(s)
+	other.Type, other.Items = nil, nil
+	_, closed := s.Items.(*bool)
+	return slices.Equal(s.Type, StringOrArrayOfString{"array"}) && !closed && schemasEqual(&other, &Schema{})
+}
+
+// itemsSchema returns the schema of the items of s, nil when it is not set or a boolean
+func itemsSchema(s *Schema) *Schema {
+	items, _ := s.Items.(*Schema)
+	return items
+}
+
+// isScalarSchema reports whether s only describes values by their scalar types
+func isScalarSchema(s *Schema) bool {
+	other := *withoutAnnotations(s)
+	other.Type = nil
+	return len(s.Type) > 0 && schemasEqual(&other, &Schema{}) &&
+		!slices.ContainsFunc(s.Type, func(t string) bool { return !slices.Contains(scalarTypes, t) })
+}
+
+// isUnionSchema reports whether s is an anyOf built by mergeSchemas
+func isUnionSchema(s *Schema) bool {
+	return len(s.AnyOf) > 0 && schemasEqual(s, &Schema{AnyOf: s.AnyOf})
+}
+
+// schemasEqual reports whether a and b generate the same JSON
+func schemasEqual(a, b *Schema) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aJSON, bJSON)
+}
@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"reflect"
+	"slices"
+)
+
+// Normalize simplifies the combinators of schema and of its nested schemas, in place:
+//   - duplicate anyOf, oneOf and allOf branches are removed;
+//   - allOf branches only made of an allOf are flattened into their parent;
+//   - anyOf, oneOf and allOf with a single branch are collapsed into their parent,
+//     unless the branch and the parent set the same keyword to different values or
+//     the collapse would change what the keywords evaluate (see collapsible).
+//
+// The draft of the schema, see SetDraft, tells whether a $ref ignores its sibling keywords.
+func Normalize(schema *Schema) {
+	if schema == nil {
+		return
+	}
+	normalize(schema, schema.draft.orDefault())
+}
+
+func normalize(schema *Schema, draft Draft) {
+	for _, v := range schema.subSchemas() {
+		normalize(v, draft)
+	}
+
+	allOf := []*Schema{}
+	for _, branch := range schema.AllOf {
+		if len(branch.AllOf) > 0 && schemasEqual(branch, &Schema{AllOf: branch.AllOf}) {
+			allOf = append(allOf, branch.AllOf...)
+		} else {
+			allOf = append(allOf, branch)
+		}
+	}
+	if len(schema.AllOf) > 0 {
+		schema.AllOf = allOf
+	}
+
+	for _, combinator := range []*[]*Schema{&schema.AnyOf, &schema.OneOf, &schema.AllOf} {
+		*combinator = uniqueSchemas(*combinator)
+		if len(*combinator) != 1 {
+			continue
+		}
+		branch := (*combinator)[0]
+		*combinator = nil
+		if !collapsible(schema, branch, draft) || !collapseInto(schema, branch) {
+			*combinator = []*Schema{branch}
+		}
+	}
+}
+
+// uniqueSchemas returns schemas without the ones generating the same JSON as a previous one
+func uniqueSchemas(schemas []*Schema) []*Schema {
+	if len(schemas) < 2 {
+		return schemas
+	}
+	result := []*Schema{}
+	for _, s := range schemas {
+		if !slices.ContainsFunc(result, func(other *Schema) bool { return schemasEqual(s, other) }) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// collapsible reports whether branch can be moved into parent without changing the
+// instances they accept. additionalProperties, items (and additionalItems, its draft-07
+// tuple form) and unevaluated* depend on the keywords next to them, and if/then/else on
+// each other, so a schema setting any of them is kept apart. A draft-07 $ref ignores its
+// sibling keywords, which the collapse would turn off.
+func collapsible(parent, branch *Schema, draft Draft) bool {
+	if draft == Draft7 && branch.Ref != "" {
+		return false
+	}
+	for _, s := range []*Schema{parent, branch} {
+		if (s.Properties != nil && s.Properties.Len() > 0) || len(s.PatternProperties) > 0 ||
+			s.AdditionalProperties != nil || s.Items != nil || len(s.PrefixItems) > 0 ||
+			s.UnevaluatedProperties != nil || s.UnevaluatedItems != nil ||
+			s.If != nil || s.Then != nil || s.Else != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// collapseInto copies the keywords of branch into parent. Nothing is changed and false is
+// returned when both set the same keyword to different values.
+func collapseInto(parent, branch *Schema) bool {
+	if parent.hasConst && branch.hasConst && !jsonEqual(parent.Const, branch.Const) {
+		return false
+	}
+
+	p, b := reflect.ValueOf(parent).Elem(), reflect.ValueOf(branch).Elem()
+	fields := []int{}
+	for i := 0; i < p.NumField(); i++ {
+		if !p.Type().Field(i).IsExported() || isEmptyValue(b.Field(i)) {
+			continue
+		}
+		if !isEmptyValue(p.Field(i)) && !reflect.DeepEqual(p.Field(i).Interface(), b.Field(i).Interface()) {
+			return false
+		}
+		fields = append(fields, i)
+	}
+
+	for _, i := range fields {
+		p.Field(i).Set(b.Field(i))
+	}
+	parent.hasConst = parent.hasConst || branch.hasConst
+	parent.unknownKeywords = append(parent.unknownKeywords, branch.unknownKeywords...)
+	return true
+}
+
+// isEmptyValue reports whether a field of Schema is unset
+func isEmptyValue(v reflect.Value) bool {
+	if properties, ok := v.Interface().(*Properties); ok {
+		return properties.Len() == 0
+	}
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	if required, ok := v.Interface().(BoolOrArrayOfString); ok {
+		return len(required.Strings) == 0 && !required.Bool
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,107 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// jsonNumberLiteral matches the JSON number grammar
+var jsonNumberLiteral = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// Number is a JSON number kept in its literal form, so that large integers
+// and decimals are emitted without losing precision
+type Number string
+
+// NewNumber returns the Number of the given literal
+func NewNumber(literal string) (Number, error) {
+	if !jsonNumberLiteral.MatchString(literal) {
+		return "", fmt.Errorf("invalid number: %s", literal)
+	}
+	return Number(literal), nil
+}
+
+// Rat returns the exact value of the number
+func (n Number) Rat() *big.Rat {
+	r, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// Cmp compares n and other, returning -1, 0 or +1
+func (n Number) Cmp(other Number) int {
+	return n.Rat().Cmp(other.Rat())
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of n
+func (n Number) Sign() int {
+	return n.Rat().Sign()
+}
+
+func (n Number) String() string {
+	return string(n)
+}
+
+func (n Number) MarshalJSON() ([]byte, error) {
+	if !jsonNumberLiteral.MatchString(string(n)) {
+		return nil, fmt.Errorf("invalid number: %s", string(n))
+	}
+	return []byte(n), nil
+}
+
+func (n *Number) UnmarshalJSON(data []byte) error {
+	num, err := NewNumber(string(data))
+	if err != nil {
+		return err
+	}
+	*n = num
+	return nil
+}
+
+// UnmarshalYAML accepts integer and float scalars. Literals which are already
+// valid JSON numbers are kept as written, other YAML notations (hex, octal, ...) are converted.
+func (n *Number) UnmarshalYAML(value *yaml.Node) error {
+	tag := value.ShortTag()
+	if value.Kind != yaml.ScalarNode || (tag != intTag && tag != floatTag) {
+		return fmt.Errorf("line %d: cannot unmarshal %s into a number", value.Line, value.Value)
+	}
+
+	if jsonNumberLiteral.MatchString(value.Value) {
+		*n = Number(value.Value)
+		return nil
+	}
+
+	if tag == intTag {
+		var i int64
+		if err := value.Decode(&i); err == nil {
+			*n = Number(strconv.FormatInt(i, 10))
+			return nil
+		}
+		var u uint64
+		if err := value.Decode(&u); err == nil {
+			*n = Number(strconv.FormatUint(u, 10))
+			return nil
+		}
+	}
+
+	var f float64
+	if err := value.Decode(&f); err != nil {
+		return err
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return fmt.Errorf("line %d: %s is not a valid JSON number", value.Line, value.Value)
+	}
+	raw, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	*n = Number(raw)
+	return nil
+}
@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PlaceholderMode selects the schema inferred for placeholder values:
+// empty mappings, empty lists and nulls
+type PlaceholderMode string
+
+// Supported placeholder modes
+const (
+	// PlaceholderExact describes the placeholder itself,
+	// e.g. an empty mapping only accepts {} and null only accepts null
+	PlaceholderExact PlaceholderMode = "exact"
+	// PlaceholderOpen accepts any value the placeholder stands for,
+	// e.g. a mapping with any key, a list of any items, any value for null
+	PlaceholderOpen PlaceholderMode = "open"
+)
+
+// ParsePlaceholderMode returns the placeholder mode matching the given name.
+// An empty name selects the default mode of the option.
+func ParsePlaceholderMode(name string) (PlaceholderMode, error) {
+	switch mode := PlaceholderMode(name); mode {
+	case "", PlaceholderExact, PlaceholderOpen:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unsupported placeholder mode %q, expected %q or %q", name, PlaceholderExact, PlaceholderOpen)
+}
+
+// TypeAnnotation pins the type of a key, e.g. x-type: string for a null placeholder.
+// The rest of the schema of the key is inferred as if it had no annotation.
+const TypeAnnotation = "x-type"
+
+// pinnedType removes the TypeAnnotation from s and returns its value
+func pinnedType(s *Schema) (StringOrArrayOfString, error) {
+	value, ok := s.CustomAnnotations[TypeAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	delete(s.CustomAnnotations, TypeAnnotation)
+
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return nil, err
+	}
+	var pinned StringOrArrayOfString
+	if err := node.Decode(&pinned); err != nil || len(pinned) == 0 {
+		return nil, fmt.Errorf("%s must be a type or a list of types, got %v", TypeAnnotation, value)
+	}
+	return pinned, nil
+}
+
+// inferType returns the type of the values written as node, see Options.Nulls
+func (c *converter) inferType(node *yaml.Node) (StringOrArrayOfString, error) {
+	if node.Tag == nullTag && c.opts.Nulls == PlaceholderOpen {
+		return nil, nil
+	}
+	return typeFromTag(node.Tag)
+}
+
+// mappingAdditionalProperties returns the additionalProperties of the mapping node
+// without annotation, see Options.EmptyMaps
+func (c *converter) mappingAdditionalProperties(node *yaml.Node, pol policy) SchemaOrBool {
+	if len(node.Content) == 0 && c.opts.EmptyMaps == PlaceholderOpen {
+		return nil
+	}
+	return pol.additionalPropertiesValue()
+}
+
+// arrayItems sets the items of the array schema s from the elements of the sequence node,
+// see Options.EmptyLists
+func (c *converter) arrayItems(s *Schema, node *yaml.Node, pointer, key string, pol policy) error {
+	items, err := c.sequenceItems(node, pointer, key, pol)
+	if err != nil {
+		return err
+	}
+	if items == nil && c.opts.EmptyLists == PlaceholderExact {
+		s.MaxItems = new(int)
+	}
+	if items != nil {
+		s.Items = items
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RequiredMode selects which properties are listed as required
+type RequiredMode string
+
+// Supported required modes
+const (
+	// RequiredByDefault makes the keys without annotation required
+	RequiredByDefault RequiredMode = "default"
+	// RequiredExplicit only makes the keys annotated with required: true required
+	RequiredExplicit RequiredMode = "explicit"
+	// RequiredNone drops every required list, annotations included
+	RequiredNone RequiredMode = "none"
+)
+
+// ParseRequiredMode returns the required mode matching the given name, RequiredByDefault when empty
+func ParseRequiredMode(name string) (RequiredMode, error) {
+	switch mode := RequiredMode(name); mode {
+	case "":
+		return RequiredByDefault, nil
+	case RequiredByDefault, RequiredExplicit, RequiredNone:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unsupported required mode %q, expected %q, %q or %q",
+		name, RequiredByDefault, RequiredExplicit, RequiredNone)
+}
+
+// AdditionalPropertiesMode selects the additionalProperties of the mappings without annotation
+type AdditionalPropertiesMode string
+
+// Supported additionalProperties modes
+const (
+	// AdditionalPropertiesFalse rejects the keys not found in the values
+	AdditionalPropertiesFalse AdditionalPropertiesMode = "false"
+	// AdditionalPropertiesTrue accepts any key
+	AdditionalPropertiesTrue AdditionalPropertiesMode = "true"
+	// AdditionalPropertiesInherit uses the boolean additionalProperties annotation
+	// of the closest annotated ancestor, false at the root
+	AdditionalPropertiesInherit AdditionalPropertiesMode = "inherit"
+)
+
+// ParseAdditionalPropertiesMode returns the additionalProperties mode matching the given name,
+// AdditionalPropertiesFalse when empty
+func ParseAdditionalPropertiesMode(name string) (AdditionalPropertiesMode, error) {
+	switch mode := AdditionalPropertiesMode(name); mode {
+	case "":
+		return AdditionalPropertiesFalse, nil
+	case AdditionalPropertiesFalse, AdditionalPropertiesTrue, AdditionalPropertiesInherit:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unsupported additionalProperties mode %q, expected %q, %q or %q",
+		name, AdditionalPropertiesFalse, AdditionalPropertiesTrue, AdditionalPropertiesInherit)
+}
+
+// policy holds the generation settings applied to the keys of a mapping.
+// It is passed down the recursion so that a subtree can change it for its descendants.
+type policy struct {
+	// requiredByDefault makes the keys without annotation required
+	requiredByDefault bool
+	// additionalProperties allows unknown keys in the mappings without annotation
+	additionalProperties bool
+}
+
+// newPolicy returns the policy of the document root
+func newPolicy(opts Options) policy {
+	return policy{
+		requiredByDefault:    opts.Required == "" || opts.Required == RequiredByDefault,
+		additionalProperties: opts.AdditionalProperties == AdditionalPropertiesTrue,
+	}
+}
+
+// additionalPropertiesValue returns the additionalProperties of a mapping without annotation
+func (p policy) additionalPropertiesValue() SchemaOrBool {
+	if p.additionalProperties {
+		// unknown keys are allowed when additionalProperties is not set
+		return nil
+	}
+	return new(bool)
+}
+
+// SubtreeAnnotation holds policy directives applied to the annotated key and all its descendants, e.g.
+//
+//	x-subtree: {additionalProperties: true, required: false}
+const SubtreeAnnotation = "x-subtree"
+
+// subtreeDirectives lists the directives accepted by SubtreeAnnotation
+var subtreeDirectives = []string{"additionalProperties", "required"}
+
+// withSubtree returns the policy p changed by the directives of a SubtreeAnnotation
+func (p policy) withSubtree(value any) (policy, error) {
+	directives, ok := value.(map[string]any)
+	if !ok {
+		return p, fmt.Errorf("%s must be a mapping of directives, got %v", SubtreeAnnotation, value)
+	}
+
+	// sort the directives for a deterministic error
+	names := make([]string, 0, len(directives))
+	for name := range directives {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		enabled, ok := directives[name].(bool)
+		switch {
+		case !slices.Contains(subtreeDirectives, name):
+			return p, fmt.Errorf("unknown %s directive %q, expected one of %s",
+				SubtreeAnnotation, name, strings.Join(subtreeDirectives, ", "))
+		case !ok:
+			return p, fmt.Errorf("%s directive %q must be a boolean, got %v", SubtreeAnnotation, name, directives[name])
+		case name == "additionalProperties":
+			p.additionalProperties = enabled
+		case name == "required":
+			p.requiredByDefault = enabled
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,160 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Properties is an ordered map of property schemas. Properties are emitted in
+// insertion order, which is the order of the keys in the values file or annotation.
+type Properties struct {
+	keys   []string
+	values map[string]*Schema
+}
+
+// NewProperties returns an empty Properties map
+func NewProperties() *Properties {
+	return &Properties{values: make(map[string]*Schema)}
+}
+
+// Len returns the number of properties
+func (p *Properties) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.keys)
+}
+
+// Get returns the schema of the given property
+func (p *Properties) Get(key string) (*Schema, bool) {
+	if p == nil {
+		return nil, false
+	}
+	v, ok := p.values[key]
+	return v, ok
+}
+
+// Set sets the schema of the given property. New properties are appended,
+// existing ones keep their position.
+func (p *Properties) Set(key string, value *Schema) {
+	if p.values == nil {
+		p.values = make(map[string]*Schema)
+	}
+	if _, ok := p.values[key]; !ok {
+		p.keys = append(p.keys, key)
+	}
+	p.values[key] = value
+}
+
+// Delete removes the given property
+func (p *Properties) Delete(key string) {
+	if p == nil {
+		return
+	}
+	if _, ok := p.values[key]; ok {
+		delete(p.values, key)
+		p.keys = slices.DeleteFunc(p.keys, func(k string) bool { return k == key })
+	}
+}
+
+// Keys returns the property names in order
+func (p *Properties) Keys() []string {
+	if p == nil {
+		return nil
+	}
+	return slices.Clone(p.keys)
+}
+
+// All iterates over the properties in order
+func (p *Properties) All() iter.Seq2[string, *Schema] {
+	return func(yield func(string, *Schema) bool) {
+		if p == nil {
+			return
+		}
+		for _, k := range p.keys {
+			if !yield(k, p.values[k]) {
+				return
+			}
+		}
+	}
+}
+
+// MarshalJSON emits the properties in order
+func (p *Properties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range p.Keys() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(p.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalYAML decodes the properties keeping their document order
+func (p *Properties) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: properties must be a mapping", node.Line)
+	}
+	*p = *NewProperties()
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		value := new(Schema)
+		if err := node.Content[i+1].Decode(value); err != nil {
+			return err
+		}
+		p.Set(node.Content[i].Value, value)
+	}
+	return nil
+}
+
+// Annotations holding the position of a property, see SetPropertyOrder
+const (
+	OrderAnnotation         = "x-order"
+	PropertyOrderAnnotation = "propertyOrder"
+)
+
+// CheckOrderAnnotation returns an error when name is neither empty nor a supported order annotation
+func CheckOrderAnnotation(name string) error {
+	switch name {
+	case "", OrderAnnotation, PropertyOrderAnnotation:
+		return nil
+	}
+	return fmt.Errorf("unsupported order annotation %q, expected %q or %q", name, OrderAnnotation, PropertyOrderAnnotation)
+}
+
+// SetPropertyOrder sets the annotation to the 1-based position of each property of s and its nested schemas.
+// Positions already set by an annotation are kept.
+func (s *Schema) SetPropertyOrder(annotation string) {
+	s.walk(func(v *Schema) {
+		i := 0
+		for _, prop := range v.Properties.All() {
+			i++
+			if prop == nil {
+				continue
+			}
+			if prop.CustomAnnotations == nil {
+				prop.CustomAnnotations = map[string]any{}
+			}
+			if _, ok := prop.CustomAnnotations[annotation]; !ok {
+				prop.CustomAnnotations[annotation] = i
+			}
+		}
+	})
+}
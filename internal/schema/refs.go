@@ -0,0 +1,407 @@
+package schema
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/krateoplatformops/yaml-to-jsonschema/internal/jsonpointer"
+	"gopkg.in/yaml.v3"
+)
+
+// resolveRefs resolves the references ($ref) to relative files found anywhere in schema:
+// properties, items, additionalProperties, combinators, conditionals and so on.
+// The referenced schema, or the part selected by its JSON pointer (#/path/to/schema),
+// replaces the reference and its own references are resolved relative to the file containing them,
+// local ones (#/definitions/...) included.
+//
+// With Options.BundleRefs the referenced schemas are placed once under $defs instead,
+// the references being rewritten to #/$defs/<name> next to their sibling keywords.
+//
+// References to URLs and absolute URIs and local references of the values file are left untouched,
+// any other failure (file missing or not readable, invalid JSON or YAML, bad pointer, reference cycle)
+// is returned as an error.
+func (c *converter) resolveRefs(schema *Schema) error {
+	r := refResolver{valuesPath: c.opts.ValuesPath, documents: map[string]any{}}
+	if c.opts.BundleRefs {
+		r.bundle = c
+	}
+	return r.resolve(schema, "")
+}
+
+// refResolver holds the state of the resolution of the references of an annotation
+type refResolver struct {
+	valuesPath string
+	// documents caches the referenced files by path
+	documents map[string]any
+	// resolving lists the references being resolved, from the outermost one
+	resolving []string
+	// bareRefs holds the bundled references whose schema is only another reference
+	bareRefs map[string]bool
+	// bundle, when set, collects the referenced schemas in its definitions
+	bundle *converter
+}
+
+// resolve resolves the references of schema and its nested schemas.
+// document is the path of the referenced file containing schema, empty for the annotation itself.
+func (r *refResolver) resolve(schema *Schema, document string) error {
+	if schema.Ref != "" {
+		file, pointer, _ := strings.Cut(schema.Ref, "#")
+
+		var path string
+		switch {
+		case file != "":
+			if u, err := url.Parse(file); err == nil && u.Scheme != "" {
+				// e.g. https://... is left to the consumers of the schema
+				break
+			}
+			path = file
+			if !filepath.IsAbs(file) {
+				path = filepath.Join(filepath.Dir(cmp.Or(document, r.valuesPath)), file)
+			}
+		case document != "":
+			// a local reference of a referenced file
+			path = document
+		}
+
+		switch {
+		case path != "" && r.bundle != nil:
+			name, err := r.define(path, pointer)
+			if err != nil {
+				return err
+			}
+			// the sibling keywords are kept and resolved below
+			schema.Ref = "#/$defs/" + jsonpointer.Escape(name)
+		case path != "":
+			return r.replace(schema, path, pointer)
+		}
+	}
+
+	for _, subSchema := range schema.subSchemas() {
+		if err := r.resolve(subSchema, document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replace replaces schema with the schema found at pointer in the file at path
+func (r *refResolver) replace(schema *Schema, path, pointer string) error {
+	ref := path + "#" + pointer
+	if slices.Contains(r.resolving, ref) {
+		return fmt.Errorf("reference cycle: %s -> %s", strings.Join(r.resolving, " -> "), ref)
+	}
+
+	relSchema, err := r.load(path, pointer)
+	if err != nil {
+		return err
+	}
+
+	r.resolving = append(r.resolving, ref)
+	err = r.resolve(&relSchema, path)
+	r.resolving = r.resolving[:len(r.resolving)-1]
+	if err != nil {
+		return err
+	}
+
+	*schema = relSchema
+	schema.HasData = true
+	return nil
+}
+
+// define returns the name of the definition of the schema found at pointer in the file at path,
+// adding it to the bundle the first time it is referenced
+func (r *refResolver) define(path, pointer string) (string, error) {
+	ref := path + "#" + pointer
+	if name, ok := r.bundle.refNames[ref]; ok {
+		// a cycle ends here too, the definition being already named, unless it is only made of
+		// references: such definitions would only point at each other
+		if i := slices.Index(r.resolving, ref); i >= 0 &&
+			!slices.ContainsFunc(r.resolving[i:], func(ref string) bool { return !r.bareRefs[ref] }) {
+			return "", fmt.Errorf("reference cycle: %s -> %s", strings.Join(r.resolving, " -> "), ref)
+		}
+		return name, nil
+	}
+
+	relSchema, err := r.load(path, pointer)
+	if err != nil {
+		return "", err
+	}
+
+	// the local references of the file become bundled definitions too
+	relSchema.Defs, relSchema.Definitions = nil, nil
+
+	name := r.bundle.defName(refDefName(path, pointer))
+	if r.bundle.refNames == nil {
+		r.bundle.refNames = map[string]string{}
+	}
+	r.bundle.refNames[ref] = name
+	if relSchema.Ref != "" && schemasEqual(withoutAnnotations(&relSchema), &Schema{Ref: relSchema.Ref}) {
+		if r.bareRefs == nil {
+			r.bareRefs = map[string]bool{}
+		}
+		r.bareRefs[ref] = true
+	}
+
+	r.resolving = append(r.resolving, ref)
+	err = r.resolve(&relSchema, path)
+	r.resolving = r.resolving[:len(r.resolving)-1]
+	if err != nil {
+		return "", err
+	}
+	r.bundle.addDef(name, &relSchema)
+	return name, nil
+}
+
+// refDefName returns the preferred definition name of a reference: the last token
+// of its JSON pointer or the name of the file without the extensions, e.g.
+// resources.schema.json#/definitions/limits gives limits, resources.schema.json resources
+func refDefName(path, pointer string) string {
+	tokens := strings.Split(pointer, "/")
+	if name := jsonpointer.Unescape(tokens[len(tokens)-1]); name != "" {
+		return name
+	}
+	name := filepath.Base(path)
+	for _, ext := range []string{filepath.Ext(name), ".schema"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name
+}
+
+// decodeSchemaFile decodes a JSON or YAML schema file, the format being chosen by the
+// extension of the file (.json, .yaml, .yml) or, for other extensions, by its content
+func decodeSchemaFile(path string, data []byte) (any, error) {
+	var obj any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err := json.Unmarshal(data, &obj)
+		return obj, err
+	case ".yaml", ".yml":
+		return decodeYAMLSchemaFile(data)
+	}
+
+	// JSON documents are YAML too, but decoding them as JSON keeps the errors precise
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(data, &obj); err == nil {
+			return obj, nil
+		}
+	}
+	return decodeYAMLSchemaFile(data)
+}
+
+// decodeYAMLSchemaFile decodes a YAML schema file to the values json.Unmarshal would return,
+// so that JSON pointers are resolved the same way in both formats
+func decodeYAMLSchemaFile(data []byte) (any, error) {
+	var obj any
+	if err := yaml.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	// the round trip gives the JSON numbers and rejects the keys that are not strings
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	obj = nil
+	err = json.Unmarshal(raw, &obj)
+	return obj, err
+}
+
+// load returns the schema found at pointer in the file at path
+func (r *refResolver) load(path, pointer string) (Schema, error) {
+	var relSchema Schema
+
+	obj, ok := r.documents[path]
+	if !ok {
+		byteValue, err := os.ReadFile(path)
+		if err != nil {
+			return relSchema, err
+		}
+		if obj, err = decodeSchemaFile(path, byteValue); err != nil {
+			return relSchema, fmt.Errorf("%s: %w", path, err)
+		}
+		r.documents[path] = obj
+	}
+
+	if pointer != "" {
+		// Found json-pointer
+		jsonPointerResultRaw, err := jsonpointer.Get(obj, pointer)
+		if err != nil {
+			return relSchema, fmt.Errorf("%s#%s: %w", path, pointer, err)
+		}
+		obj = jsonPointerResultRaw
+	}
+
+	jsonPointerResultMarshaled, err := json.Marshal(obj)
+	if err != nil {
+		return relSchema, err
+	}
+	if err := json.Unmarshal(jsonPointerResultMarshaled, &relSchema); err != nil {
+		return relSchema, fmt.Errorf("%s#%s: %w", path, pointer, err)
+	}
+	return relSchema, nil
+}
+
+// wrapRefSiblings moves the $ref of the schemas having sibling keywords into an allOf branch:
+// before 2019-09 the keywords next to a $ref are ignored
+func wrapRefSiblings(schema *Schema) {
+	schema.walk(func(s *Schema) {
+		if s.Ref == "" {
+			return
+		}
+		siblings := *s
+		siblings.Ref = ""
+		if schemasEqual(&siblings, &Schema{}) {
+			return
+		}
+		siblings.AllOf = append([]*Schema{{Ref: s.Ref}}, s.AllOf...)
+		*s = siblings
+	})
+}
+
+// refSite is the annotation where a local reference, or a reference to a definition,
+// was first found, see recordLocalRefs
+type refSite struct {
+	keyNode *yaml.Node
+	pointer string
+}
+
+// isLocalRef reports whether ref points into the generated schema, e.g. #/properties/git.
+// References to definitions are left to the definitions themselves.
+func isLocalRef(ref string) bool {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok || !strings.HasPrefix(pointer, "/") {
+		return false
+	}
+	first, _, _ := strings.Cut(pointer[1:], "/")
+	return first != "$defs" && first != "definitions"
+}
+
+// recordLocalRefs records the annotation of keyNode as the site of the local references and of the
+// references to definitions of schema, so that the references failing to resolve are reported there
+func (c *converter) recordLocalRefs(keyNode *yaml.Node, pointer string, schema *Schema) {
+	schema.walk(func(s *Schema) {
+		if _, isDef := defRefName(s.Ref); !isLocalRef(s.Ref) && !isDef {
+			return
+		}
+		if c.refSites == nil {
+			c.refSites = map[string]refSite{}
+		}
+		if _, ok := c.refSites[s.Ref]; !ok {
+			c.refSites[s.Ref] = refSite{keyNode: keyNode, pointer: pointer}
+		}
+	})
+}
+
+// resolveLocalRefs resolves the local references of the generated document schema and of the
+// generated definitions, once all the keys are known. With Options.BundleRefs they are kept as
+// they are, unless the documents are combined into a single schema, otherwise the referencing
+// schema is replaced by a copy of the referenced one with the title and description of the former.
+func (c *converter) resolveLocalRefs(document *Schema) error {
+	if len(c.refSites) == 0 {
+		return nil
+	}
+
+	// the pointers select the keywords of the Schema struct
+	document.SetDraft(Draft2020)
+	data, err := json.Marshal(document)
+	document.SetDraft(c.opts.Draft)
+	if err != nil {
+		return err
+	}
+
+	combined := c.opts.Documents == DocumentsOneOf || c.opts.Documents == DocumentsMerge
+	keep := c.opts.BundleRefs && !combined
+
+	var resolve func(s *Schema, resolving []string) error
+	resolve = func(s *Schema, resolving []string) error {
+		if !isLocalRef(s.Ref) {
+			for _, subSchema := range s.subSchemas() {
+				if err := resolve(subSchema, resolving); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		ref := s.Ref
+		target, err := localRefTarget(data, ref, keep)
+		if err == nil && slices.Contains(resolving, ref) {
+			err = fmt.Errorf("reference cycle: %s -> %s", strings.Join(resolving, " -> "), ref)
+		}
+		if err == nil && !keep {
+			err = resolve(target, append(resolving, ref))
+		}
+		if err != nil {
+			site, ok := c.refSites[ref]
+			if !ok || len(resolving) > 0 {
+				// reported by the outermost reference
+				return err
+			}
+			d := c.annotationErrorAt(site.keyNode, site.pointer, ErrInvalidRef, fmt.Errorf("%s: %w", ref, err))
+			if err := c.report(d); err != nil {
+				return err
+			}
+			s.Ref = ""
+			return nil
+		}
+		if keep {
+			return nil
+		}
+
+		// the annotations of the referenced key do not describe this one
+		target.Title, target.Description = s.Title, s.Description
+		*s = *target
+		return nil
+	}
+
+	if err := resolve(document, nil); err != nil {
+		return err
+	}
+	for _, def := range c.defs {
+		if err := resolve(def, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localRefTarget returns a copy of the schema found at the local reference ref of the
+// marshaled document data. Only its existence is checked when check is set.
+func localRefTarget(data []byte, ref string, check bool) (*Schema, error) {
+	raw := json.RawMessage(data)
+	pointer := strings.TrimPrefix(ref, "#")
+	for _, token := range strings.Split(pointer, "/")[1:] {
+		token = jsonpointer.Unescape(token)
+
+		// the raw members keep the order of the properties of the copy
+		var members map[string]json.RawMessage
+		var items []json.RawMessage
+		found := false
+		if err := json.Unmarshal(raw, &members); err == nil {
+			raw, found = members[token]
+		} else if err := json.Unmarshal(raw, &items); err == nil {
+			if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(items) {
+				raw, found = items[i], true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no schema found at %s", pointer)
+		}
+	}
+	if check {
+		return nil, nil
+	}
+
+	var target Schema
+	if err := json.Unmarshal(raw, &target); err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
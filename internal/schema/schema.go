@@ -1,11 +1,12 @@
 package schema
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/santhosh-tekuri/jsonschema/v6"
@@ -170,10 +171,11 @@ func (s *StringOrArrayOfString) Matches(typeString string) bool {
 }
 
 // MarshalJSON custom marshal method for Schema. It inlines the CustomAnnotations fields
+// and emits the keywords of the draft selected with SetDraft
 func (s *Schema) MarshalJSON() ([]byte, error) {
 	// Create a map to hold all the fields
 	type Alias Schema
-	data := make(map[string]any)
+	data := make(map[string]json.RawMessage)
 
 	// Marshal the Schema struct (excluding CustomAnnotations)
 	alias := (*Alias)(s)
@@ -182,70 +184,113 @@ func (s *Schema) MarshalJSON() ([]byte, error) {
 		return nil, err
 	}
 
-	// Unmarshal the JSON back into the map
+	// Unmarshal the JSON back into the map. Values are kept raw so that
+	// nested schemas are emitted exactly as they marshaled themselves.
 	if err := json.Unmarshal(aliasJSON, &data); err != nil {
 		return nil, err
 	}
 
 	// Rimuovi "required" se vuoto
-	if required, ok := data["required"]; ok {
-		if arr, ok := required.([]any); ok && len(arr) == 0 {
-			delete(data, "required")
-		}
+	if required, ok := data["required"]; ok && string(required) == "[]" {
+		delete(data, "required")
 	}
 
-	// inline the CustomAnnotations fields
-	for key, value := range s.CustomAnnotations {
-		data[key] = value
+	// like an empty map, empty properties are omitted
+	if s.Properties.Len() == 0 {
+		delete(data, "properties")
 	}
 
 	delete(data, "CustomAnnotations")
 
+	// const may legitimately be null, which omitempty drops
+	if s.hasConst && s.Const == nil {
+		data["const"] = json.RawMessage("null")
+	}
+
+	if err := s.draft.translateKeywords(data); err != nil {
+		return nil, err
+	}
+
+	// inline the CustomAnnotations fields
+	for key, value := range s.CustomAnnotations {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		data[key] = raw
+	}
+
 	// Marshal the final map into JSON
 	return json.Marshal(data)
 }
 
 // Schema struct contains yaml tags for reading, json for writing (creating the jsonschema)
 type Schema struct {
-	AdditionalProperties SchemaOrBool          `yaml:"additionalProperties,omitempty" json:"additionalProperties,omitempty"`
-	Default              any                   `yaml:"default,omitempty"              json:"default,omitempty"`
-	Then                 *Schema               `yaml:"then,omitempty"                 json:"then,omitempty"`
-	PatternProperties    map[string]*Schema    `yaml:"patternProperties,omitempty"    json:"patternProperties,omitempty"`
-	Properties           map[string]*Schema    `yaml:"properties,omitempty"           json:"properties,omitempty"`
-	If                   *Schema               `yaml:"if,omitempty"                   json:"if,omitempty"`
-	Minimum              *int                  `yaml:"minimum,omitempty"              json:"minimum,omitempty"`
-	MultipleOf           *int                  `yaml:"multipleOf,omitempty"           json:"multipleOf,omitempty"`
-	ExclusiveMaximum     *int                  `yaml:"exclusiveMaximum,omitempty"     json:"exclusiveMaximum,omitempty"`
-	Items                *Schema               `yaml:"items,omitempty"                json:"items,omitempty"`
-	ExclusiveMinimum     *int                  `yaml:"exclusiveMinimum,omitempty"     json:"exclusiveMinimum,omitempty"`
-	Maximum              *int                  `yaml:"maximum,omitempty"              json:"maximum,omitempty"`
-	Else                 *Schema               `yaml:"else,omitempty"                 json:"else,omitempty"`
-	Pattern              string                `yaml:"pattern,omitempty"              json:"pattern,omitempty"`
-	Const                any                   `yaml:"const,omitempty"                json:"const,omitempty"`
-	Ref                  string                `yaml:"$ref,omitempty"                 json:"$ref,omitempty"`
-	Schema               string                `yaml:"$schema,omitempty"              json:"$schema,omitempty"`
-	Id                   string                `yaml:"$id,omitempty"                  json:"$id,omitempty"`
-	Format               string                `yaml:"format,omitempty"               json:"format,omitempty"`
-	Description          string                `yaml:"description,omitempty"          json:"description,omitempty"`
-	Title                string                `yaml:"title,omitempty"                json:"title,omitempty"`
-	Type                 StringOrArrayOfString `yaml:"type,omitempty"                 json:"type,omitempty"`
-	AnyOf                []*Schema             `yaml:"anyOf,omitempty"                json:"anyOf,omitempty"`
-	AllOf                []*Schema             `yaml:"allOf,omitempty"                json:"allOf,omitempty"`
-	OneOf                []*Schema             `yaml:"oneOf,omitempty"                json:"oneOf,omitempty"`
-	Not                  *Schema               `yaml:"not,omitempty"                json:"not,omitempty"`
-	Examples             []string              `yaml:"examples,omitempty"             json:"examples,omitempty"`
-	Enum                 []string              `yaml:"enum,omitempty"                 json:"enum,omitempty"`
-	HasData              bool                  `yaml:"-"                              json:"-"`
-	Deprecated           bool                  `yaml:"deprecated,omitempty"           json:"deprecated,omitempty"`
-	ReadOnly             bool                  `yaml:"readOnly,omitempty"           json:"readOnly,omitempty"`
-	WriteOnly            bool                  `yaml:"writeOnly,omitempty"           json:"writeOnly,omitempty"`
-	Required             BoolOrArrayOfString   `yaml:"required,omitempty"             json:"required,omitempty"`
-	CustomAnnotations    map[string]any        `yaml:"-"                              json:",omitempty"`
-	MinLength            *int                  `yaml:"minLength,omitempty"              json:"minLength,omitempty"`
-	MaxLength            *int                  `yaml:"maxLength,omitempty"              json:"maxLength,omitempty"`
-	MinItems             *int                  `yaml:"minItems,omitempty"              json:"minItems,omitempty"`
-	MaxItems             *int                  `yaml:"maxItems,omitempty"              json:"maxItems,omitempty"`
-	UniqueItems          bool                  `yaml:"uniqueItems,omitempty"          json:"uniqueItems,omitempty"`
+	AdditionalProperties  SchemaOrBool          `yaml:"additionalProperties,omitempty" json:"additionalProperties,omitempty"`
+	Default               any                   `yaml:"default,omitempty"              json:"default,omitempty"`
+	Then                  *Schema               `yaml:"then,omitempty"                 json:"then,omitempty"`
+	PatternProperties     map[string]*Schema    `yaml:"patternProperties,omitempty"    json:"patternProperties,omitempty"`
+	Properties            *Properties           `yaml:"properties,omitempty"           json:"properties,omitempty"`
+	If                    *Schema               `yaml:"if,omitempty"                   json:"if,omitempty"`
+	Minimum               *Number               `yaml:"minimum,omitempty"              json:"minimum,omitempty"`
+	MultipleOf            *Number               `yaml:"multipleOf,omitempty"           json:"multipleOf,omitempty"`
+	ExclusiveMaximum      *Number               `yaml:"exclusiveMaximum,omitempty"     json:"exclusiveMaximum,omitempty"`
+	Items                 SchemaOrBool          `yaml:"items,omitempty"                json:"items,omitempty"`
+	ExclusiveMinimum      *Number               `yaml:"exclusiveMinimum,omitempty"     json:"exclusiveMinimum,omitempty"`
+	Maximum               *Number               `yaml:"maximum,omitempty"              json:"maximum,omitempty"`
+	Else                  *Schema               `yaml:"else,omitempty"                 json:"else,omitempty"`
+	Pattern               string                `yaml:"pattern,omitempty"              json:"pattern,omitempty"`
+	Const                 any                   `yaml:"const,omitempty"                json:"const,omitempty"`
+	Ref                   string                `yaml:"$ref,omitempty"                 json:"$ref,omitempty"`
+	Schema                string                `yaml:"$schema,omitempty"              json:"$schema,omitempty"`
+	Id                    string                `yaml:"$id,omitempty"                  json:"$id,omitempty"`
+	Format                string                `yaml:"format,omitempty"               json:"format,omitempty"`
+	Description           string                `yaml:"description,omitempty"          json:"description,omitempty"`
+	Title                 string                `yaml:"title,omitempty"                json:"title,omitempty"`
+	Type                  StringOrArrayOfString `yaml:"type,omitempty"                 json:"type,omitempty"`
+	AnyOf                 []*Schema             `yaml:"anyOf,omitempty"                json:"anyOf,omitempty"`
+	AllOf                 []*Schema             `yaml:"allOf,omitempty"                json:"allOf,omitempty"`
+	OneOf                 []*Schema             `yaml:"oneOf,omitempty"                json:"oneOf,omitempty"`
+	Not                   *Schema               `yaml:"not,omitempty"                json:"not,omitempty"`
+	Examples              []any                 `yaml:"examples,omitempty"             json:"examples,omitempty"`
+	Enum                  []any                 `yaml:"enum,omitempty"                 json:"enum,omitempty"`
+	HasData               bool                  `yaml:"-"                              json:"-"`
+	Skip                  bool                  `yaml:"skip,omitempty"                 json:"-"`
+	Deprecated            bool                  `yaml:"deprecated,omitempty"           json:"deprecated,omitempty"`
+	ReadOnly              bool                  `yaml:"readOnly,omitempty"           json:"readOnly,omitempty"`
+	WriteOnly             bool                  `yaml:"writeOnly,omitempty"           json:"writeOnly,omitempty"`
+	Required              BoolOrArrayOfString   `yaml:"required,omitempty"             json:"required,omitempty"`
+	CustomAnnotations     map[string]any        `yaml:"-"                              json:",omitempty"`
+	MinLength             *int                  `yaml:"minLength,omitempty"              json:"minLength,omitempty"`
+	MaxLength             *int                  `yaml:"maxLength,omitempty"              json:"maxLength,omitempty"`
+	MinItems              *int                  `yaml:"minItems,omitempty"              json:"minItems,omitempty"`
+	MaxItems              *int                  `yaml:"maxItems,omitempty"              json:"maxItems,omitempty"`
+	UniqueItems           bool                  `yaml:"uniqueItems,omitempty"          json:"uniqueItems,omitempty"`
+	PrefixItems           []*Schema             `yaml:"prefixItems,omitempty"          json:"prefixItems,omitempty"`
+	Defs                  map[string]*Schema    `yaml:"$defs,omitempty"                json:"$defs,omitempty"`
+	Definitions           map[string]*Schema    `yaml:"definitions,omitempty"          json:"definitions,omitempty"`
+	DependentRequired     map[string][]string   `yaml:"dependentRequired,omitempty"    json:"dependentRequired,omitempty"`
+	DependentSchemas      map[string]*Schema    `yaml:"dependentSchemas,omitempty"     json:"dependentSchemas,omitempty"`
+	MinProperties         *int                  `yaml:"minProperties,omitempty"        json:"minProperties,omitempty"`
+	MaxProperties         *int                  `yaml:"maxProperties,omitempty"        json:"maxProperties,omitempty"`
+	PropertyNames         *Schema               `yaml:"propertyNames,omitempty"        json:"propertyNames,omitempty"`
+	Contains              *Schema               `yaml:"contains,omitempty"             json:"contains,omitempty"`
+	MinContains           *int                  `yaml:"minContains,omitempty"          json:"minContains,omitempty"`
+	MaxContains           *int                  `yaml:"maxContains,omitempty"          json:"maxContains,omitempty"`
+	UnevaluatedProperties SchemaOrBool          `yaml:"unevaluatedProperties,omitempty" json:"unevaluatedProperties,omitempty"`
+	UnevaluatedItems      SchemaOrBool          `yaml:"unevaluatedItems,omitempty"     json:"unevaluatedItems,omitempty"`
+	Comment               string                `yaml:"$comment,omitempty"             json:"$comment,omitempty"`
+	Anchor                string                `yaml:"$anchor,omitempty"              json:"$anchor,omitempty"`
+	ContentEncoding       string                `yaml:"contentEncoding,omitempty"      json:"contentEncoding,omitempty"`
+	ContentMediaType      string                `yaml:"contentMediaType,omitempty"     json:"contentMediaType,omitempty"`
+
+	// draft selects the keywords emitted by MarshalJSON
+	draft Draft
+	// hasConst tells a "const: null" annotation apart from a missing const
+	hasConst bool
+	// unknownKeywords are the keys found by UnmarshalYAML that are
+	// neither known keywords nor custom annotations
+	unknownKeywords []UnknownKeyword
 }
 
 func NewSchema(schemaType string) *Schema {
@@ -259,16 +304,24 @@ func NewSchema(schemaType string) *Schema {
 	}
 }
 
-// getJsonKeys returns a slice of all JSON tag values from the Schema struct fields.
+// getJsonKeys returns a slice of all JSON keywords of the Schema struct fields,
+// plus the keywords of older drafts decoded by hand in UnmarshalYAML.
 // This is used to identify known fields during YAML unmarshaling to separate them
-// from custom annotations.
+// from custom annotations and unknown keywords.
 func (s Schema) getJsonKeys() []string {
-	result := []string{}
+	result := []string{"additionalItems", "dependencies"}
 	t := reflect.TypeOf(s)
 
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		result = append(result, field.Tag.Get("json"))
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			// generator directives are only read from annotations
+			name, _, _ = strings.Cut(field.Tag.Get("yaml"), ",")
+		}
+		if name != "" && name != "-" {
+			result = append(result, name)
+		}
 	}
 	return result
 }
@@ -277,6 +330,8 @@ func (s Schema) getJsonKeys() []string {
 // It handles both standard schema fields and custom annotations (prefixed with "x-").
 // Custom annotations are stored in the CustomAnnotations map while standard fields
 // are unmarshaled directly into the Schema struct.
+// Keywords of older drafts (array form of items, additionalItems, dependencies)
+// are decoded into their 2020-12 counterparts.
 func (s *Schema) UnmarshalYAML(node *yaml.Node) error {
 	// Create an alias type to avoid recursion
 	type schemaAlias Schema
@@ -284,11 +339,92 @@ func (s *Schema) UnmarshalYAML(node *yaml.Node) error {
 	// copy all existing fields
 	*alias = schemaAlias(*s)
 
+	// Draft specific keywords are set aside, the others are decoded through the alias
+	known := *node
+	known.Content = nil
+	var itemsArray, additionalItems, dependencies *yaml.Node
+	schemaOrBool := map[string]*yaml.Node{}
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		keyNode := node.Content[i]
+		valueNode := node.Content[i+1]
+		switch {
+		case keyNode.Value == "items" && valueNode.Kind == yaml.SequenceNode:
+			itemsArray = valueNode
+		case slices.Contains(schemaOrBoolKeys, keyNode.Value):
+			schemaOrBool[keyNode.Value] = valueNode
+		case keyNode.Value == "const":
+			alias.hasConst = true
+			known.Content = append(known.Content, keyNode, valueNode)
+		case keyNode.Value == "additionalItems":
+			additionalItems = valueNode
+		case keyNode.Value == "dependencies":
+			dependencies = valueNode
+		default:
+			known.Content = append(known.Content, keyNode, valueNode)
+		}
+	}
+
 	// Unmarshal known fields into alias
-	if err := node.Decode(alias); err != nil {
+	if err := known.Decode(alias); err != nil {
 		return err
 	}
 
+	for key, valueNode := range schemaOrBool {
+		value, err := decodeSchemaOrBool(valueNode)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "items":
+			alias.Items = value
+		case "additionalProperties":
+			alias.AdditionalProperties = value
+		case "unevaluatedProperties":
+			alias.UnevaluatedProperties = value
+		case "unevaluatedItems":
+			alias.UnevaluatedItems = value
+		}
+	}
+
+	if itemsArray != nil {
+		if err := itemsArray.Decode(&alias.PrefixItems); err != nil {
+			return err
+		}
+		if additionalItems != nil {
+			value, err := decodeSchemaOrBool(additionalItems)
+			if err != nil {
+				return err
+			}
+			alias.Items = value
+		}
+	}
+
+	if dependencies != nil {
+		for i := 0; i < len(dependencies.Content)-1; i += 2 {
+			name := dependencies.Content[i].Value
+			dependency := dependencies.Content[i+1]
+			if dependency.Kind == yaml.SequenceNode {
+				if alias.DependentRequired == nil {
+					alias.DependentRequired = make(map[string][]string)
+				}
+				var required []string
+				if err := dependency.Decode(&required); err != nil {
+					return err
+				}
+				alias.DependentRequired[name] = required
+			} else {
+				if alias.DependentSchemas == nil {
+					alias.DependentSchemas = make(map[string]*Schema)
+				}
+				var schema Schema
+				if err := dependency.Decode(&schema); err != nil {
+					return err
+				}
+				alias.DependentSchemas[name] = &schema
+			}
+		}
+	}
+
 	// Initialize CustomAnnotations map
 	alias.CustomAnnotations = make(map[string]any)
 
@@ -304,8 +440,14 @@ func (s *Schema) UnmarshalYAML(node *yaml.Node) error {
 			continue
 		}
 
-		// Unmarshal unknown fields into the CustomAnnotations map
-		if !strings.HasPrefix(key, CustomAnnotationPrefix) {
+		// Unknown keywords are recorded to be reported, custom annotations are kept
+		if !strings.HasPrefix(key, CustomAnnotationPrefix) && key != PropertyOrderAnnotation {
+			alias.unknownKeywords = append(alias.unknownKeywords, UnknownKeyword{
+				Name:       key,
+				Line:       keyNode.Line,
+				Column:     keyNode.Column,
+				Suggestion: suggestKeyword(key, knownKeys),
+			})
 			continue
 		}
 		var value any
@@ -320,270 +462,257 @@ func (s *Schema) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
-// Set sets the HasData field to true
-func (s *Schema) Set() {
-	s.HasData = true
-}
+// schemaOrBoolKeys are the keywords accepting either a boolean or a schema
+var schemaOrBoolKeys = []string{"items", "additionalProperties", "unevaluatedProperties", "unevaluatedItems"}
 
-// DisableRequiredProperties recursively disables all required property validations throughout the schema.
-// This includes:
-// - Setting the root schema's required field to an empty array
-// - Recursively disabling required properties in all nested schemas (properties, items, etc.)
-// - Handling all conditional schemas (if/then/else)
-// - Processing all composition schemas (anyOf/oneOf/allOf)
-func (s *Schema) DisableRequiredProperties() {
-	s.Required = NewBoolOrArrayOfString([]string{}, false)
-	for _, v := range s.Properties {
-		v.DisableRequiredProperties()
-	}
-	if s.Items != nil {
-		s.Items.DisableRequiredProperties()
-	}
-
-	if s.AnyOf != nil {
-		for _, v := range s.AnyOf {
-			v.DisableRequiredProperties()
-		}
-	}
-	if s.OneOf != nil {
-		for _, v := range s.OneOf {
-			v.DisableRequiredProperties()
-		}
-	}
-	if s.AllOf != nil {
-		for _, v := range s.AllOf {
-			v.DisableRequiredProperties()
+// decodeSchemaOrBool decodes a keyword accepting either a boolean or a schema.
+// Booleans are returned as *bool, schemas as *Schema.
+func decodeSchemaOrBool(node *yaml.Node) (SchemaOrBool, error) {
+	if node.ShortTag() == boolTag {
+		b := new(bool)
+		if err := node.Decode(b); err != nil {
+			return nil, err
 		}
+		return b, nil
 	}
-	if s.If != nil {
-		s.If.DisableRequiredProperties()
-	}
-	if s.Else != nil {
-		s.Else.DisableRequiredProperties()
-	}
-	if s.Then != nil {
-		s.Then.DisableRequiredProperties()
-	}
-	if s.Not != nil {
-		s.Not.DisableRequiredProperties()
+	subSchema := new(Schema)
+	if err := node.Decode(subSchema); err != nil {
+		return nil, err
 	}
+	return subSchema, nil
+}
 
-	// Add handling for AdditionalProperties when it's a Schema
-	if s.AdditionalProperties != nil {
-		if subSchema, ok := s.AdditionalProperties.(Schema); ok {
-			subSchema.DisableRequiredProperties()
-			s.AdditionalProperties = subSchema
-		}
+// UnmarshalJSON decodes a JSON schema document with the same rules as UnmarshalYAML,
+// so that custom annotations, keywords of older drafts and the order of properties are kept.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	node, err := jsonToNode(dec)
+	if err != nil {
+		return err
 	}
+	return node.Decode(s)
 }
 
-// ToJson converts the data to raw json
-func (s Schema) ToJson() ([]byte, error) {
-	res, err := json.MarshalIndent(&s, "", "  ")
+// jsonToNode reads the next JSON value from dec and converts it into the equivalent YAML node,
+// keeping the order of object members and the literal form of numbers
+func jsonToNode(dec *json.Decoder) (*yaml.Node, error) {
+	tok, err := dec.Token()
 	if err != nil {
 		return nil, err
 	}
-	return res, nil
-}
 
-// Supported format values according to JSON Schema specification
-const (
-	FormatDateTime       = "date-time"
-	FormatTime           = "time"
-	FormatDate           = "date"
-	FormatDuration       = "duration"
-	FormatEmail          = "email"
-	FormatIDNEmail       = "idn-email"
-	FormatHostname       = "hostname"
-	FormatIDNHostname    = "idn-hostname"
-	FormatIPv4           = "ipv4"
-	FormatIPv6           = "ipv6"
-	FormatUUID           = "uuid"
-	FormatURI            = "uri"
-	FormatURIReference   = "uri-reference"
-	FormatIRI            = "iri"
-	FormatIRIReference   = "iri-reference"
-	FormatURITemplate    = "uri-template"
-	FormatJSONPointer    = "json-pointer"
-	FormatRelJSONPointer = "relative-json-pointer"
-	FormatRegex          = "regex"
-)
+	switch v := tok.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: arrayTag}
+		if v == '{' {
+			node = &yaml.Node{Kind: yaml.MappingNode, Tag: mapTag}
+		}
+		for dec.More() {
+			if v == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Tag: strTag, Value: key.(string)})
+			}
+			item, err := jsonToNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, item)
+		}
+		// consume the closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: intTag, Value: v.String()}, nil
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: floatTag, Value: v.String()}, nil
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: strTag, Value: v}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: boolTag, Value: strconv.FormatBool(v)}, nil
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: nullTag, Value: "null"}, nil
+}
 
-var supportedFormats = map[string]bool{
-	FormatDateTime: true, FormatTime: true, FormatDate: true,
-	FormatDuration: true, FormatEmail: true, FormatIDNEmail: true,
-	FormatHostname: true, FormatIDNHostname: true, FormatIPv4: true,
-	FormatIPv6: true, FormatUUID: true, FormatURI: true,
-	FormatURIReference: true, FormatIRI: true, FormatIRIReference: true,
-	FormatURITemplate: true, FormatJSONPointer: true,
-	FormatRelJSONPointer: true, FormatRegex: true,
+// HasConst reports whether a const value, null included, is set
+func (s *Schema) HasConst() bool {
+	return s.hasConst || s.Const != nil
 }
 
-// Validate performs comprehensive validation of the schema
-func (s Schema) Validate() error {
-	// Validate schema syntax
-	if err := s.validateSchemaSyntax(); err != nil {
-		return err
+// AllowsValue reports whether value satisfies the enum and const keywords of s.
+// Values are compared by their JSON representation, so 1 and 1.0 are equal.
+func (s *Schema) AllowsValue(value any) bool {
+	if s.HasConst() && !jsonEqual(s.Const, value) {
+		return false
 	}
-
-	// Validate type constraints
-	if err := s.validateTypeConstraints(); err != nil {
-		return err
+	if len(s.Enum) == 0 {
+		return true
 	}
+	return slices.ContainsFunc(s.Enum, func(v any) bool { return jsonEqual(v, value) })
+}
 
-	// Validate numeric constraints
-	if err := s.validateNumericConstraints(); err != nil {
-		return err
+// jsonEqual reports whether a and b have the same JSON value
+func jsonEqual(a, b any) bool {
+	normalize := func(v any) (any, error) {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var res any
+		err = json.Unmarshal(raw, &res)
+		return res, err
 	}
+	na, errA := normalize(a)
+	nb, errB := normalize(b)
+	return errA == nil && errB == nil && reflect.DeepEqual(na, nb)
+}
 
-	// Validate string constraints
-	if err := s.validateStringConstraints(); err != nil {
-		return err
-	}
+// Set sets the HasData field to true
+func (s *Schema) Set() {
+	s.HasData = true
+}
 
-	// Validate array constraints
-	if err := s.validateArrayConstraints(); err != nil {
-		return err
+// subSchemas returns the schemas nested directly in s
+func (s *Schema) subSchemas() []*Schema {
+	result := []*Schema{}
+	for _, v := range s.Properties.All() {
+		result = append(result, v)
 	}
-
-	// Validate nested schemas
-	if err := s.validateNestedSchemas(); err != nil {
-		return err
+	for _, m := range []map[string]*Schema{s.PatternProperties, s.Defs, s.Definitions, s.DependentSchemas} {
+		for _, v := range m {
+			result = append(result, v)
+		}
 	}
-
-	return nil
-}
-
-func (s Schema) validateSchemaSyntax() error {
-	jsonStr, err := s.ToJson()
-	if err != nil {
-		return fmt.Errorf("failed to convert schema to JSON: %w", err)
+	for _, l := range [][]*Schema{s.PrefixItems, s.AllOf, s.AnyOf, s.OneOf} {
+		result = append(result, l...)
+	}
+	result = append(result, s.Contains, s.PropertyNames, s.If, s.Then, s.Else, s.Not)
+	for _, v := range []SchemaOrBool{s.Items, s.AdditionalProperties, s.UnevaluatedProperties, s.UnevaluatedItems} {
+		if subSchema, ok := v.(*Schema); ok {
+			result = append(result, subSchema)
+		}
 	}
+	return slices.DeleteFunc(result, func(v *Schema) bool { return v == nil })
+}
 
-	c := jsonschema.NewCompiler()
-	if err := c.AddResource("schema.json", jsonStr); err != nil {
-		return fmt.Errorf("invalid schema syntax: %w", err)
+// walk calls fn for s and all its nested schemas
+func (s *Schema) walk(fn func(*Schema)) {
+	fn(s)
+	for _, v := range s.subSchemas() {
+		v.walk(fn)
 	}
+}
 
-	return s.Type.Validate()
+// UnknownKeywords returns the unknown keywords found in s and its nested schemas
+func (s *Schema) UnknownKeywords() []UnknownKeyword {
+	result := []UnknownKeyword{}
+	s.walk(func(v *Schema) {
+		result = append(result, v.unknownKeywords...)
+	})
+	return result
 }
 
-func (s Schema) validateTypeConstraints() error {
-	if s.Const != nil && !s.Type.IsEmpty() {
-		return errors.New("cannot use both 'const' and 'type' in the same schema")
+// SetDraft recursively selects the draft whose keywords are emitted by MarshalJSON
+func (s *Schema) SetDraft(d Draft) {
+	s.draft = d
+	for _, v := range s.subSchemas() {
+		v.SetDraft(d)
 	}
+}
 
-	if s.Enum != nil && !s.Type.IsEmpty() {
-		return errors.New("cannot use both 'enum' and 'type' in the same schema")
+// DisableRequiredProperties recursively disables all required property validations throughout the schema.
+// This includes:
+// - Setting the root schema's required field to an empty array
+// - Recursively disabling required properties in all nested schemas (properties, items, etc.)
+// - Handling all conditional schemas (if/then/else)
+// - Processing all composition schemas (anyOf/oneOf/allOf)
+func (s *Schema) DisableRequiredProperties() {
+	s.Required = NewBoolOrArrayOfString([]string{}, false)
+	for _, v := range s.subSchemas() {
+		v.DisableRequiredProperties()
 	}
-
-	return nil
 }
 
-func (s Schema) validateNumericConstraints() error {
-	if !s.hasNumericConstraints() {
-		return nil
+// ToJson converts the data to raw json
+func (s Schema) ToJson() ([]byte, error) {
+	res, err := json.MarshalIndent(&s, "", "  ")
+	if err != nil {
+		return nil, err
 	}
+	return res, nil
+}
 
-	if !s.Type.IsEmpty() && !s.Type.Matches("number") && !s.Type.Matches("integer") {
-		return fmt.Errorf("numeric constraints can only be used with number or integer types, got %v", s.Type)
+// Validate checks the schema against the official meta-schema of its draft and rejects
+// contradictory bounds, e.g. minimum greater than maximum, at any depth.
+// Opinionated checks that do not make a schema invalid are implemented as lint rules, see Lint.
+func (s Schema) Validate() error {
+	jsonStr, err := s.ToJson()
+	if err != nil {
+		return fmt.Errorf("failed to convert schema to JSON: %w", err)
 	}
 
-	if s.MultipleOf != nil && *s.MultipleOf <= 0 {
-		return errors.New("multipleOf must be greater than 0")
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(jsonStr))
+	if err != nil {
+		return fmt.Errorf("failed to convert schema to JSON: %w", err)
 	}
 
-	if s.Minimum != nil && s.ExclusiveMinimum != nil {
-		return errors.New("cannot use both minimum and exclusiveMinimum")
+	meta, err := s.draft.metaSchema()
+	if err != nil {
+		return fmt.Errorf("unable to load meta-schema: %w", err)
 	}
-
-	if s.Maximum != nil && s.ExclusiveMaximum != nil {
-		return errors.New("cannot use both maximum and exclusiveMaximum")
+	if err := meta.Validate(doc); err != nil {
+		return fmt.Errorf("invalid schema syntax: %w", err)
 	}
 
-	return nil
-}
-
-func (s Schema) validateStringConstraints() error {
-	if s.Format != "" {
-		if !s.Type.IsEmpty() && !s.Type.Matches("string") {
-			return fmt.Errorf("format can only be used with string type, got %v", s.Type)
-		}
-
-		if !supportedFormats[s.Format] {
-			return fmt.Errorf("unsupported format: %s", s.Format)
+	var rangeErr error
+	s.walk(func(v *Schema) {
+		if rangeErr == nil {
+			rangeErr = validateRanges(v)
 		}
-	}
+	})
+	return rangeErr
+}
 
-	if s.Pattern != "" {
-		if !s.Type.IsEmpty() && !s.Type.Matches("string") {
-			return fmt.Errorf("pattern can only be used with string type, got %v", s.Type)
+// validateRanges checks that the lower bounds of s do not exceed its upper bounds:
+// such a schema is valid against the meta-schema but no value satisfies it
+func validateRanges(s *Schema) error {
+	if s.Minimum != nil && s.Maximum != nil && s.Minimum.Cmp(*s.Maximum) > 0 {
+		return fmt.Errorf("minimum (%s) cannot be greater than maximum (%s)", s.Minimum, s.Maximum)
+	}
+	for _, bound := range []struct {
+		lower, upper *Number
+		msg          string
+	}{
+		{s.ExclusiveMinimum, s.Maximum, "exclusiveMinimum (%s) must be less than maximum (%s)"},
+		{s.Minimum, s.ExclusiveMaximum, "minimum (%s) must be less than exclusiveMaximum (%s)"},
+		{s.ExclusiveMinimum, s.ExclusiveMaximum, "exclusiveMinimum (%s) must be less than exclusiveMaximum (%s)"},
+	} {
+		if bound.lower != nil && bound.upper != nil && bound.lower.Cmp(*bound.upper) >= 0 {
+			return fmt.Errorf(bound.msg, bound.lower, bound.upper)
 		}
 	}
-
-	if s.Format != "" && s.Pattern != "" {
-		return errors.New("cannot use both format and pattern in the same schema")
-	}
-
 	if s.MaxLength != nil && s.MinLength != nil && *s.MinLength > *s.MaxLength {
 		return fmt.Errorf("minLength (%d) cannot be greater than maxLength (%d)", *s.MinLength, *s.MaxLength)
 	}
-
-	return nil
-}
-
-func (s Schema) validateArrayConstraints() error {
-	if s.Items != nil {
-		if !s.Type.IsEmpty() && !s.Type.Matches("array") {
-			return fmt.Errorf("items can only be used with array type, got %v", s.Type)
-		}
-
-		if err := s.Items.Validate(); err != nil {
-			return fmt.Errorf("invalid items schema: %w", err)
-		}
-	}
-
-	if s.MinItems != nil || s.MaxItems != nil {
-		if !s.Type.IsEmpty() && !s.Type.Matches("array") {
-			return fmt.Errorf("minItems/maxItems can only be used with array type, got %v", s.Type)
-		}
-
-		if s.MinItems != nil && s.MaxItems != nil && *s.MaxItems < *s.MinItems {
-			return fmt.Errorf("maxItems (%d) cannot be less than minItems (%d)", *s.MaxItems, *s.MinItems)
-		}
+	if s.MinItems != nil && s.MaxItems != nil && *s.MaxItems < *s.MinItems {
+		return fmt.Errorf("maxItems (%d) cannot be less than minItems (%d)", *s.MaxItems, *s.MinItems)
 	}
-
-	return nil
-}
-
-func (s Schema) validateNestedSchemas() error {
-	// Validate combinatorial schemas
-	for _, schemas := range [][]*Schema{s.AllOf, s.AnyOf, s.OneOf} {
-		for _, schema := range schemas {
-			if err := schema.Validate(); err != nil {
-				return err
-			}
-		}
+	if s.MinContains != nil && s.MaxContains != nil && *s.MaxContains < *s.MinContains {
+		return fmt.Errorf("maxContains (%d) cannot be less than minContains (%d)", *s.MaxContains, *s.MinContains)
 	}
-
-	// Validate conditional schemas
-	for _, schema := range []*Schema{s.If, s.Then, s.Else, s.Not} {
-		if schema != nil {
-			if err := schema.Validate(); err != nil {
-				return err
-			}
-		}
+	if s.MinProperties != nil && s.MaxProperties != nil && *s.MaxProperties < *s.MinProperties {
+		return fmt.Errorf("maxProperties (%d) cannot be less than minProperties (%d)", *s.MaxProperties, *s.MinProperties)
 	}
-
 	return nil
 }
 
-func (s Schema) hasNumericConstraints() bool {
-	return s.Minimum != nil || s.Maximum != nil ||
-		s.ExclusiveMinimum != nil || s.ExclusiveMaximum != nil ||
-		s.MultipleOf != nil
-}
-
 func typeFromTag(tag string) ([]string, error) {
 	switch tag {
 	case nullTag:
@@ -610,8 +739,7 @@ func typeFromTag(tag string) ([]string, error) {
 // Then the property is added to the parents required property list
 func FixRequiredProperties(schema *Schema) error {
 	if schema.Properties != nil {
-		for propName, propValue := range schema.Properties {
-			FixRequiredProperties(propValue)
+		for propName, propValue := range schema.Properties.All() {
 			if propValue.Required.Bool && !slices.Contains(schema.Required.Strings, propName) {
 				schema.Required.Strings = append(schema.Required.Strings, propName)
 			}
@@ -622,48 +750,8 @@ func FixRequiredProperties(schema *Schema) error {
 		}
 	}
 
-	if schema.Then != nil {
-		FixRequiredProperties(schema.Then)
-	}
-
-	if schema.If != nil {
-		FixRequiredProperties(schema.If)
-	}
-
-	if schema.Else != nil {
-		FixRequiredProperties(schema.Else)
-	}
-
-	if schema.Items != nil {
-		FixRequiredProperties(schema.Items)
-	}
-
-	if schema.AdditionalProperties != nil {
-		if subSchema, ok := schema.AdditionalProperties.(Schema); ok {
-			FixRequiredProperties(&subSchema)
-		}
-	}
-
-	if len(schema.AnyOf) > 0 {
-		for _, subSchema := range schema.AnyOf {
-			FixRequiredProperties(subSchema)
-		}
-	}
-
-	if len(schema.AllOf) > 0 {
-		for _, subSchema := range schema.AllOf {
-			FixRequiredProperties(subSchema)
-		}
-	}
-
-	if len(schema.OneOf) > 0 {
-		for _, subSchema := range schema.OneOf {
-			FixRequiredProperties(subSchema)
-		}
-	}
-
-	if schema.Not != nil {
-		FixRequiredProperties(schema.Not)
+	for _, subSchema := range schema.subSchemas() {
+		FixRequiredProperties(subSchema)
 	}
 
 	return nil
@@ -1,10 +1,13 @@
 package schema
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"testing"
 
 	"github.com/magiconair/properties/assert"
+	"github.com/santhosh-tekuri/jsonschema/v6"
 	"gopkg.in/yaml.v3"
 )
 
@@ -75,7 +78,7 @@ func TestValidate(t *testing.T) {
 # pattern: ^foo
 # format: ipv4
 # @schema`,
-			expectedValid: false,
+			expectedValid: true,
 		},
 		{
 			comment: `
@@ -168,6 +171,94 @@ func TestValidate(t *testing.T) {
 # @schema
 # type: string
 # minItems: 1
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# minimum: 0.5
+# maximum: 1
+# multipleOf: 0.01
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# minimum: 1.5
+# maximum: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# exclusiveMinimum: 1
+# exclusiveMaximum: 1.0
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# minimum: 9007199254740993
+# maximum: 9007199254740992
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# enum: [a, b]
+# type: string
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# minLength: -1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# multipleOf: -0.5
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# minProperties: 2
+# maxProperties: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# minContains: 2
+# maxContains: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# minProperties: -1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# properties:
+#   name:
+#     minLength: 2
+#     maxLength: 1
 # @schema`,
 			expectedValid: false,
 		},
@@ -185,7 +276,7 @@ func TestValidate(t *testing.T) {
 # type: string
 # uniqueItems: false
 # @schema`,
-			expectedValid: false,
+			expectedValid: true,
 		},
 	}
 
@@ -226,3 +317,456 @@ x-custom-foo: bar
 	assert.Equal(t, schema.Type, StringOrArrayOfString{"string"})
 	assert.Equal(t, schema.CustomAnnotations["x-custom-foo"], "bar")
 }
+
+func TestPropertiesOrder(t *testing.T) {
+	data := `{"properties":{"zeta":{"type":"integer"},"alpha":{"properties":{"b":{},"a":{}}},"mid":{}}}`
+
+	var schema Schema
+	if err := json.Unmarshal([]byte(data), &schema); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, schema.Properties.Keys(), []string{"zeta", "alpha", "mid"})
+
+	out, err := json.Marshal(schema.Properties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(out), `{"zeta":{"type":"integer"},"alpha":{"properties":{"b":{},"a":{}}},"mid":{}}`)
+
+	schema.Properties.Delete("alpha")
+	schema.Properties.Set("zeta", NewSchema("string"))
+	assert.Equal(t, schema.Properties.Keys(), []string{"zeta", "mid"})
+}
+
+func TestMarshalDraft(t *testing.T) {
+	comment := `
+# @schema
+# $defs:
+#   port:
+#     type: integer
+# items:
+#   - type: string
+# additionalItems:
+#   $ref: "#/definitions/port"
+# dependencies:
+#   tls: [cert]
+# @schema`
+
+	tests := []struct {
+		draft    Draft
+		expected string
+	}{
+		{
+			draft:    Draft7,
+			expected: `{"additionalItems":{"$ref":"#/definitions/port"},"definitions":{"port":{"type":"integer"}},"dependencies":{"tls":["cert"]},"items":[{"type":"string"}]}`,
+		},
+		{
+			draft:    Draft2019,
+			expected: `{"$defs":{"port":{"type":"integer"}},"additionalItems":{"$ref":"#/$defs/port"},"dependentRequired":{"tls":["cert"]},"items":[{"type":"string"}]}`,
+		},
+		{
+			draft:    Draft2020,
+			expected: `{"$defs":{"port":{"type":"integer"}},"dependentRequired":{"tls":["cert"]},"items":{"$ref":"#/$defs/port"},"prefixItems":[{"type":"string"}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		schema, _, err := GetSchemaFromComment(comment)
+		if err != nil {
+			t.Fatalf("unable to parse the schema: %v", err)
+		}
+		schema.SetDraft(test.draft)
+		if err := schema.Validate(); err != nil {
+			t.Errorf("draft %s: expected a valid schema, got %v", test.draft, err)
+		}
+		res, err := json.Marshal(&schema)
+		if err != nil {
+			t.Fatalf("draft %s: unable to marshal the schema: %v", test.draft, err)
+		}
+		assert.Equal(t, string(res), test.expected)
+	}
+}
+
+func TestKeywordsRoundTrip(t *testing.T) {
+	comment := `
+# @schema
+# $comment: shared settings
+# $anchor: settings
+# type: object
+# minProperties: 1
+# maxProperties: 5
+# propertyNames:
+#   pattern: ^[a-z]+$
+# additionalProperties:
+#   type: string
+# unevaluatedProperties: false
+# x-custom: true
+# properties:
+#   hosts:
+#     type: array
+#     contains:
+#       const: localhost
+#     minContains: 1
+#     maxContains: 2
+#     unevaluatedItems:
+#       type: string
+#   cert:
+#     type: string
+#     contentEncoding: base64
+#     contentMediaType: application/x-pem-file
+# @schema`
+
+	schema, _, err := GetSchemaFromComment(comment)
+	if err != nil {
+		t.Fatalf("unable to parse the schema: %v", err)
+	}
+	schema.SetDraft(Draft2020)
+	if err := schema.Validate(); err != nil {
+		t.Fatalf("expected a valid schema, got %v", err)
+	}
+
+	first, err := json.Marshal(&schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Schema
+	if err := json.Unmarshal(first, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	decoded.SetDraft(Draft2020)
+	second, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, string(second), string(first))
+	if _, ok := decoded.AdditionalProperties.(*Schema); !ok {
+		t.Errorf("expected additionalProperties to be decoded as a schema, got %T", decoded.AdditionalProperties)
+	}
+	hosts, _ := decoded.Properties.Get("hosts")
+	assert.Equal(t, *hosts.MaxContains, 2)
+	cert, _ := decoded.Properties.Get("cert")
+	assert.Equal(t, cert.ContentEncoding, "base64")
+}
+
+func TestUnknownKeywords(t *testing.T) {
+	comment := `Port of the service
+# @schema
+# type: integer
+# minimun: 1
+# x-custom: foo
+# properties:
+#   name:
+#     tpye: string
+# foo: bar
+# @schema`
+
+	schema, _, err := GetSchemaFromComment(comment)
+	if err != nil {
+		t.Fatalf("unable to parse the schema: %v", err)
+	}
+
+	expected := []UnknownKeyword{
+		{Name: "minimun", Line: 4, Column: 3, Suggestion: "minimum"},
+		{Name: "foo", Line: 9, Column: 3},
+		{Name: "tpye", Line: 8, Column: 7, Suggestion: "type"},
+	}
+	assert.Equal(t, schema.UnknownKeywords(), expected)
+}
+
+func TestSuggestKeyword(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "minimun", expected: "minimum"},
+		{name: "requried", expected: "required"},
+		{name: "maxLenght", expected: "maxLength"},
+		{name: "Description", expected: "description"},
+		{name: "additionalProperty", expected: "additionalProperties"},
+		{name: "foo", expected: ""},
+		{name: "completelyunrelated", expected: ""},
+	}
+
+	knownKeys := Schema{}.getJsonKeys()
+	for _, test := range tests {
+		assert.Equal(t, suggestKeyword(test.name, knownKeys), test.expected, test.name)
+	}
+}
+
+func TestNumberPrecision(t *testing.T) {
+	comment := `
+# @schema
+# minimum: 0x10
+# maximum: 9223372036854775807
+# multipleOf: 0.01
+# exclusiveMaximum: 18446744073709551616
+# @schema`
+
+	schema, _, err := GetSchemaFromComment(comment)
+	if err != nil {
+		t.Fatalf("unable to parse the schema: %v", err)
+	}
+	res, err := json.Marshal(&schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(res), `{"exclusiveMaximum":18446744073709551616,"maximum":9223372036854775807,"minimum":16,"multipleOf":0.01}`)
+
+	var decoded Schema
+	if err := json.Unmarshal(res, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, *decoded.ExclusiveMaximum, Number("18446744073709551616"))
+
+	if _, _, err := GetSchemaFromComment("# @schema\n# minimum: foo\n# @schema"); err == nil {
+		t.Errorf("expected a non numeric minimum to be rejected")
+	}
+}
+
+func TestEnumConstExamplesTypes(t *testing.T) {
+	comment := `
+# @schema
+# enum: [1, "2", true, null, 1.5]
+# examples:
+#   - name: foo
+#     port: 80
+#   - 3
+# @schema`
+
+	schema, _, err := GetSchemaFromComment(comment)
+	if err != nil {
+		t.Fatalf("unable to parse the schema: %v", err)
+	}
+	res, err := json.Marshal(&schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(res), `{"enum":[1,"2",true,null,1.5],"examples":[{"name":"foo","port":80},3]}`)
+
+	assert.Equal(t, schema.AllowsValue(1), true)
+	assert.Equal(t, schema.AllowsValue(1.0), true)
+	assert.Equal(t, schema.AllowsValue(nil), true)
+	assert.Equal(t, schema.AllowsValue("1"), false)
+	assert.Equal(t, schema.AllowsValue(2), false)
+
+	nullConst, _, err := GetSchemaFromComment("# @schema\n# const: null\n# @schema")
+	if err != nil {
+		t.Fatalf("unable to parse the schema: %v", err)
+	}
+	res, err = json.Marshal(&nullConst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(res), `{"const":null}`)
+	assert.Equal(t, nullConst.AllowsValue("foo"), false)
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		comment  string
+		disabled []string
+		expected []string
+	}{
+		{
+			comment: `
+# @schema
+# type: string
+# format: ipv4
+# @schema`,
+			expected: []string{},
+		},
+		{
+			comment: `
+# @schema
+# pattern: ^foo
+# format: ipv4
+# @schema`,
+			expected: []string{"format-with-pattern"},
+		},
+		{
+			comment: `
+# @schema
+# type: string
+# enum: [a, b]
+# minItems: 1
+# @schema`,
+			expected: []string{"enum-with-type", "array-keyword-type"},
+		},
+		{
+			comment: `
+# @schema
+# type: string
+# enum: [a, b]
+# minItems: 1
+# @schema`,
+			disabled: []string{"enum-with-type"},
+			expected: []string{"array-keyword-type"},
+		},
+		{
+			comment: `
+# @schema
+# type: string
+# enum: [a, b]
+# @schema`,
+			disabled: []string{LintAll},
+			expected: []string{},
+		},
+		{
+			comment: `
+# @schema
+# format: foo
+# @schema`,
+			expected: []string{"unknown-format"},
+		},
+	}
+
+	for _, test := range tests {
+		schema, _, err := GetSchemaFromComment(test.comment)
+		if err != nil {
+			t.Fatalf("unable to parse the schema %s: %v", test.comment, err)
+		}
+		rules := []string{}
+		for _, finding := range schema.Lint(test.disabled) {
+			rules = append(rules, finding.Rule)
+		}
+		assert.Equal(t, rules, test.expected, test.comment)
+	}
+
+	if err := CheckLintRules([]string{"enum-with-type", LintAll}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := CheckLintRules([]string{"doesnotexist"}); err == nil {
+		t.Errorf("expected an unknown lint rule to be rejected")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "duplicate branches",
+			input:    `{"items":{"anyOf":[{"type":"string"},{"type":"string"},{"type":"integer"}]},"type":"array"}`,
+			expected: `{"items":{"anyOf":[{"type":"string"},{"type":"integer"}]},"type":"array"}`,
+		},
+		{
+			name:     "single branch",
+			input:    `{"items":{"anyOf":[{"type":"string"},{"type":"string"}]},"type":"array"}`,
+			expected: `{"items":{"type":"string"},"type":"array"}`,
+		},
+		{
+			name:     "single branch with parent keywords",
+			input:    `{"oneOf":[{"minLength":1,"type":"string"}],"title":"name"}`,
+			expected: `{"minLength":1,"title":"name","type":"string"}`,
+		},
+		{
+			name:     "conflicting single branch",
+			input:    `{"anyOf":[{"type":"string"}],"type":"integer"}`,
+			expected: `{"anyOf":[{"type":"string"}],"type":"integer"}`,
+		},
+		{
+			name:     "nested allOf",
+			input:    `{"allOf":[{"allOf":[{"minimum":1},{"maximum":5}]},{"maximum":5},{"multipleOf":2}]}`,
+			expected: `{"allOf":[{"minimum":1},{"maximum":5},{"multipleOf":2}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		var schema Schema
+		if err := json.Unmarshal([]byte(test.input), &schema); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		Normalize(&schema)
+		out, err := json.Marshal(&schema)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		assert.Equal(t, string(out), test.expected, test.name)
+	}
+}
+
+func TestNormalizeKeepsValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		instances []string
+	}{
+		{
+			name:      "additionalProperties in a single branch",
+			input:     `{"properties":{"a":{}},"anyOf":[{"additionalProperties":false}]}`,
+			instances: []string{`{"a":1}`, `{"b":1}`, `{}`},
+		},
+		{
+			name:      "properties in a single branch",
+			input:     `{"additionalProperties":false,"properties":{},"allOf":[{"properties":{"b":{}},"required":["b"]}]}`,
+			instances: []string{`{"b":1}`, `{}`, `{"c":1}`},
+		},
+		{
+			name:      "items in a single branch",
+			input:     `{"items":[{"type":"string"}],"oneOf":[{"additionalItems":false}]}`,
+			instances: []string{`["a"]`, `["a",1]`},
+		},
+		{
+			name:      "if in a single branch",
+			input:     `{"then":{"minimum":5},"anyOf":[{"if":{"type":"integer"}}]}`,
+			instances: []string{`1`, `6`, `"a"`},
+		},
+		{
+			name:      "draft-07 $ref in a single branch",
+			input:     `{"type":"integer","definitions":{"name":{"type":"string"}},"allOf":[{"$ref":"#/definitions/name"}]}`,
+			instances: []string{`1`, `"a"`},
+		},
+		{
+			name:      "collapsed branch",
+			input:     `{"title":"port","anyOf":[{"type":"integer","minimum":1}]}`,
+			instances: []string{`1`, `0`, `"a"`},
+		},
+	}
+
+	for _, test := range tests {
+		before := compileDraft7(t, []byte(test.input))
+		var schema Schema
+		if err := json.Unmarshal([]byte(test.input), &schema); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		Normalize(&schema)
+		out, err := json.Marshal(&schema)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		after := compileDraft7(t, out)
+
+		for _, instance := range test.instances {
+			var value any
+			if err := json.Unmarshal([]byte(instance), &value); err != nil {
+				t.Fatal(err)
+			}
+			if validBefore, validAfter := before.Validate(value) == nil, after.Validate(value) == nil; validBefore != validAfter {
+				t.Errorf("%s: %s is valid %v before normalization and %v after: %s", test.name, instance, validBefore, validAfter, out)
+			}
+		}
+	}
+}
+
+// compileDraft7 compiles the given JSON schema as a draft-07 one
+func compileDraft7(t *testing.T, data []byte) *jsonschema.Schema {
+	t.Helper()
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft7)
+	if err := compiler.AddResource("schema.json", doc); err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := compiler.Compile("schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compiled
+}
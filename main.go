@@ -1,14 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/krateoplatformops/yaml-to-jsonschema/internal/config"
-	"github.com/krateoplatformops/yaml-to-jsonschema/internal/schema"
-	"gopkg.in/yaml.v3"
+	"github.com/krateoplatformops/yaml-to-jsonschema/pkg/generator"
 )
 
 func main() {
@@ -30,20 +30,66 @@ func main() {
 		os.Exit(1)
 	}
 
-	var values yaml.Node
-	err = yaml.Unmarshal(content, &values)
+	draft, err := generator.ParseDraft(cfg.Draft)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 
-	dir := filepath.Dir(cfg.YAMLFile)
+	additionalProperties, err := generator.ParseAdditionalPropertiesMode(cfg.AdditionalProperties)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	documents, err := generator.ParseDocumentsMode(cfg.Documents)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	placeholders := make([]generator.PlaceholderMode, 3)
+	for i, name := range []string{cfg.EmptyMaps, cfg.EmptyLists, cfg.Nulls} {
+		if placeholders[i], err = generator.ParsePlaceholderMode(name); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	required := generator.RequiredByDefault
+	if cfg.DisableRequired {
+		required = generator.RequiredNone
+	} else if !cfg.RequiredByDefault {
+		required = generator.RequiredExplicit
+	}
+
 	base := filepath.Base(cfg.YAMLFile)
 	ext := filepath.Ext(cfg.YAMLFile)
 
-	res := schema.FromYAML(dir, &values, nil)
-
-	sch, err := res.ToJson()
+	schemas, err := generator.GenerateAll(context.Background(), content, generator.Options{
+		ValuesPath:           cfg.YAMLFile,
+		MaxErrors:            cfg.MaxErrors,
+		Draft:                draft,
+		Strict:               cfg.Strict,
+		DisabledLintRules:    cfg.DisabledLintRules,
+		OrderAnnotation:      cfg.OrderAnnotation,
+		Required:             required,
+		AdditionalProperties: additionalProperties,
+		AnchorDefs:           cfg.AnchorDefs,
+		BundleRefs:           cfg.BundleRefs,
+		DefsFile:             cfg.DefsFile,
+		Documents:            documents,
+		DisableNormalize:     !cfg.Normalize,
+		EmptyMaps:            placeholders[0],
+		EmptyLists:           placeholders[1],
+		Nulls:                placeholders[2],
+		Report: func(d *generator.Diagnostic) {
+			fmt.Fprintln(os.Stderr, d)
+		},
+	})
+	if _, ok := err.(generator.Diagnostics); ok {
+		os.Exit(1)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
@@ -55,12 +101,24 @@ func main() {
 		os.Exit(1)
 	}
 
-	schemaFilePath := filepath.Join(cfg.DestinationDir,
-		fmt.Sprintf("%s.schema.json", strings.TrimSuffix(base, ext)))
+	for i, res := range schemas {
+		sch, err := res.ToJson()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
 
-	err = os.WriteFile(schemaFilePath, sch, 0644)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		// the documents of a split multi-document file are numbered from 1
+		name := strings.TrimSuffix(base, ext)
+		if len(schemas) > 1 {
+			name = fmt.Sprintf("%s-%d", name, i+1)
+		}
+		schemaFilePath := filepath.Join(cfg.DestinationDir, fmt.Sprintf("%s.schema.json", name))
+
+		err = os.WriteFile(schemaFilePath, sch, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
 	}
 }
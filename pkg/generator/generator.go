@@ -0,0 +1,323 @@
+// Package generator converts YAML values files into JSON Schema documents.
+//
+// It is the public entry point of yaml-to-jsonschema and is safe to embed in
+// long-running services: failures are returned as errors and never terminate
+// the process.
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/krateoplatformops/yaml-to-jsonschema/internal/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is a generated JSON Schema document
+type Schema = schema.Schema
+
+// Diagnostic reports a problem found while building the schema, located
+// by file, line, column and JSON pointer of the property being built
+type Diagnostic = schema.Diagnostic
+
+// Diagnostics is the list of problems found in a run. It implements error.
+type Diagnostics = schema.Diagnostics
+
+// KeyError reports a failure that occurred while building the schema of a key.
+// errors.As matches it on the diagnostics about a key.
+type KeyError = schema.KeyError
+
+// Severity tells how serious a diagnostic is
+type Severity = schema.Severity
+
+const (
+	SeverityError   = schema.SeverityError
+	SeverityWarning = schema.SeverityWarning
+)
+
+// Draft identifies the JSON Schema specification version of the generated schema
+type Draft = schema.Draft
+
+// Supported JSON Schema drafts
+const (
+	Draft7       = schema.Draft7
+	Draft2019    = schema.Draft2019
+	Draft2020    = schema.Draft2020
+	DefaultDraft = schema.DefaultDraft
+)
+
+// ParseDraft returns the draft matching the given name (e.g. "draft-07", "2020-12")
+func ParseDraft(name string) (Draft, error) {
+	return schema.ParseDraft(name)
+}
+
+// RequiredMode selects which properties are listed as required
+type RequiredMode = schema.RequiredMode
+
+// Supported required modes
+const (
+	RequiredByDefault = schema.RequiredByDefault
+	RequiredExplicit  = schema.RequiredExplicit
+	RequiredNone      = schema.RequiredNone
+)
+
+// ParseRequiredMode returns the required mode matching the given name ("default", "explicit", "none")
+func ParseRequiredMode(name string) (RequiredMode, error) {
+	return schema.ParseRequiredMode(name)
+}
+
+// AdditionalPropertiesMode selects the additionalProperties of the mappings without annotation
+type AdditionalPropertiesMode = schema.AdditionalPropertiesMode
+
+// Supported additionalProperties modes
+const (
+	AdditionalPropertiesFalse   = schema.AdditionalPropertiesFalse
+	AdditionalPropertiesTrue    = schema.AdditionalPropertiesTrue
+	AdditionalPropertiesInherit = schema.AdditionalPropertiesInherit
+)
+
+// ParseAdditionalPropertiesMode returns the additionalProperties mode matching the given name
+// ("false", "true", "inherit")
+func ParseAdditionalPropertiesMode(name string) (AdditionalPropertiesMode, error) {
+	return schema.ParseAdditionalPropertiesMode(name)
+}
+
+// Custom annotations handled by the generator
+const (
+	PassthroughAnnotation = schema.PassthroughAnnotation
+	InternalAnnotation    = schema.InternalAnnotation
+)
+
+// DocumentsMode selects how the documents of a multi-document input are converted
+type DocumentsMode = schema.DocumentsMode
+
+// Supported documents modes
+const (
+	DocumentsSplit = schema.DocumentsSplit
+	DocumentsOneOf = schema.DocumentsOneOf
+	DocumentsMerge = schema.DocumentsMerge
+)
+
+// ParseDocumentsMode returns the documents mode matching the given name ("split", "oneOf", "merge")
+func ParseDocumentsMode(name string) (DocumentsMode, error) {
+	return schema.ParseDocumentsMode(name)
+}
+
+// PlaceholderMode selects the schema inferred for empty mappings, empty lists and nulls
+type PlaceholderMode = schema.PlaceholderMode
+
+// Supported placeholder modes
+const (
+	PlaceholderExact = schema.PlaceholderExact
+	PlaceholderOpen  = schema.PlaceholderOpen
+)
+
+// ParsePlaceholderMode returns the placeholder mode matching the given name ("exact", "open")
+func ParsePlaceholderMode(name string) (PlaceholderMode, error) {
+	return schema.ParsePlaceholderMode(name)
+}
+
+// TypeAnnotation pins the type of a key whose schema is otherwise inferred
+const TypeAnnotation = schema.TypeAnnotation
+
+// UseAnnotation references a definition of the library by name, e.g. x-use: port
+const UseAnnotation = schema.UseAnnotation
+
+// SubtreeAnnotation holds policy directives applied to the annotated key and all its descendants
+const SubtreeAnnotation = schema.SubtreeAnnotation
+
+// LintRule is an opinionated check on an annotation, reported as a warning
+type LintRule = schema.LintRule
+
+// LintRules lists all the lint rules, each one can be disabled by name
+var LintRules = schema.LintRules
+
+// Annotations holding the position of a property, see Options.OrderAnnotation
+const (
+	OrderAnnotation         = schema.OrderAnnotation
+	PropertyOrderAnnotation = schema.PropertyOrderAnnotation
+)
+
+// LintAll disables every lint rule when listed in Options.DisabledLintRules
+const LintAll = schema.LintAll
+
+// Error kinds returned by Generate. Use errors.Is to match them.
+var (
+	ErrInvalidYAML       = errors.New("invalid yaml")
+	ErrInvalidDocument   = schema.ErrInvalidDocument
+	ErrInvalidAnnotation = schema.ErrInvalidAnnotation
+	ErrInvalidSchema     = schema.ErrInvalidSchema
+	ErrUnsupportedTag    = schema.ErrUnsupportedTag
+	ErrInvalidRef        = schema.ErrInvalidRef
+	ErrUnknownKeyword    = schema.ErrUnknownKeyword
+	ErrInvalidDefault    = schema.ErrInvalidDefault
+	ErrLint              = schema.ErrLint
+	ErrTooManyErrors     = schema.ErrTooManyErrors
+)
+
+// Options configures Generate
+type Options struct {
+	// ValuesPath is the path of the values file the input was read from.
+	// Relative $ref annotations are resolved against its directory.
+	ValuesPath string
+	// MaxErrors stops the generation once that many errors have been found.
+	// Zero means no limit.
+	MaxErrors int
+	// Draft selects the JSON Schema draft of the output, any name accepted by ParseDraft.
+	// DefaultDraft when empty.
+	Draft Draft
+	// Strict reports unknown annotation keywords as errors instead of warnings
+	Strict bool
+	// DisabledLintRules lists the lint rules not to run on annotations, LintAll disables them all
+	DisabledLintRules []string
+	// Required selects which properties are listed as required, RequiredByDefault when empty
+	Required RequiredMode
+	// AdditionalProperties selects the additionalProperties of the mappings without annotation,
+	// AdditionalPropertiesFalse when empty
+	AdditionalProperties AdditionalPropertiesMode
+	// OrderAnnotation, when set to OrderAnnotation or PropertyOrderAnnotation,
+	// emits the position of each property in its parent mapping under that name
+	OrderAnnotation string
+	// AnchorDefs emits the anchored mappings as a single $defs entry referenced
+	// by $ref from each key using them, instead of duplicating their schema
+	AnchorDefs bool
+	// DisableNormalize keeps the generated combinators as they are, see Normalize
+	DisableNormalize bool
+	// Documents selects how the documents of a multi-document input are converted,
+	// DocumentsSplit when empty
+	Documents DocumentsMode
+	// EmptyMaps selects the schema of the mappings without keys nor annotation,
+	// PlaceholderExact (only {} is accepted) when empty
+	EmptyMaps PlaceholderMode
+	// EmptyLists selects the items of the empty lists without annotation,
+	// PlaceholderOpen (any item) when empty
+	EmptyLists PlaceholderMode
+	// Nulls selects the type of the null values without annotation,
+	// PlaceholderExact (type null) when empty
+	Nulls PlaceholderMode
+	// DefsFile is the path of a JSON or YAML file declaring definitions that annotations
+	// reference by name, only the referenced ones being emitted, see UseAnnotation
+	DefsFile string
+	// BundleRefs places the schema files referenced by the annotations once under $defs,
+	// instead of copying them in place of each reference
+	BundleRefs bool
+	// Report, when set, is called with each diagnostic as soon as it is found,
+	// warnings included
+	Report func(*Diagnostic)
+}
+
+// Normalize simplifies the combinators of a schema in place: duplicate anyOf, oneOf and allOf
+// branches are removed, nested allOf are flattened and single-branch combinators are collapsed
+// into their parent. Generate applies it unless Options.DisableNormalize is set.
+func Normalize(s *Schema) {
+	schema.Normalize(s)
+}
+
+// Generate builds the JSON Schema describing the given YAML input.
+//
+// The whole input is processed even when problems are found: keys with broken
+// annotations fall back to inference and all the problems are returned together
+// as Diagnostics, along with the best-effort schema.
+//
+// A multi-document input requires a combining Options.Documents mode, use
+// GenerateAll to get one schema per document.
+func Generate(ctx context.Context, input []byte, opts Options) (*Schema, error) {
+	schemas, err := GenerateAll(ctx, input, opts)
+	if len(schemas) > 1 {
+		return nil, fmt.Errorf("%w: found %d documents, use GenerateAll or the %q or %q documents mode",
+			ErrInvalidDocument, len(schemas), DocumentsOneOf, DocumentsMerge)
+	}
+	if len(schemas) == 0 {
+		return nil, err
+	}
+	return schemas[0], err
+}
+
+// GenerateAll builds the JSON Schemas describing the documents of the given YAML stream:
+// one per document in DocumentsSplit mode, a single one combining them otherwise.
+// Problems are reported as in Generate.
+func GenerateAll(ctx context.Context, input []byte, opts Options) ([]*Schema, error) {
+	if err := schema.CheckLintRules(opts.DisabledLintRules); err != nil {
+		return nil, err
+	}
+	if err := schema.CheckOrderAnnotation(opts.OrderAnnotation); err != nil {
+		return nil, err
+	}
+	if _, err := schema.ParseRequiredMode(string(opts.Required)); err != nil {
+		return nil, err
+	}
+	if _, err := schema.ParseAdditionalPropertiesMode(string(opts.AdditionalProperties)); err != nil {
+		return nil, err
+	}
+	if opts.Draft != "" {
+		draft, err := schema.ParseDraft(string(opts.Draft))
+		if err != nil {
+			return nil, err
+		}
+		opts.Draft = draft
+	}
+	if _, err := schema.ParseDocumentsMode(string(opts.Documents)); err != nil {
+		return nil, err
+	}
+	for _, mode := range []PlaceholderMode{opts.EmptyMaps, opts.EmptyLists, opts.Nulls} {
+		if _, err := schema.ParsePlaceholderMode(string(mode)); err != nil {
+			return nil, err
+		}
+	}
+
+	documents := []*yaml.Node{}
+	decoder := yaml.NewDecoder(bytes.NewReader(input))
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidYAML, err)
+		}
+		documents = append(documents, &document)
+	}
+	if len(documents) == 0 {
+		// an empty or comment-only input is an empty mapping, converted like any other
+		// document so that the draft and the root annotation of its comment apply
+		documents = append(documents, &yaml.Node{
+			Kind:        yaml.DocumentNode,
+			HeadComment: strings.TrimSpace(string(input)),
+			Line:        1,
+			Column:      1,
+			Content:     []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map", Line: 1, Column: 1}},
+		})
+	}
+
+	res, diags, err := schema.FromYAMLDocuments(ctx, documents, schema.Options{
+		ValuesPath:           opts.ValuesPath,
+		MaxErrors:            opts.MaxErrors,
+		Draft:                opts.Draft,
+		Strict:               opts.Strict,
+		DisabledLintRules:    opts.DisabledLintRules,
+		OrderAnnotation:      opts.OrderAnnotation,
+		Required:             opts.Required,
+		AdditionalProperties: opts.AdditionalProperties,
+		AnchorDefs:           opts.AnchorDefs,
+		Documents:            opts.Documents,
+		DisableNormalize:     opts.DisableNormalize,
+		EmptyMaps:            opts.EmptyMaps,
+		EmptyLists:           opts.EmptyLists,
+		Nulls:                opts.Nulls,
+		BundleRefs:           opts.BundleRefs,
+		DefsFile:             opts.DefsFile,
+		Report:               opts.Report,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if diags.HasErrors() {
+		return res, diags
+	}
+	return res, nil
+}
@@ -0,0 +1,1412 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   string
+		expected error
+	}{
+		{
+			name:     "invalid yaml",
+			values:   "foo: [bar",
+			expected: ErrInvalidYAML,
+		},
+		{
+			name: "unclosed annotation",
+			values: `
+# @schema
+# type: string
+foo: bar
+`,
+			expected: ErrInvalidAnnotation,
+		},
+		{
+			name: "invalid annotation",
+			values: `
+# @schema
+# type: doesnotexist
+# @schema
+foo: bar
+`,
+			expected: ErrInvalidSchema,
+		},
+		{
+			name: "missing ref",
+			values: `
+# @schema
+# $ref: ./testdata/missing.json#/foo
+# @schema
+foo: bar
+`,
+			expected: ErrInvalidRef,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := Generate(context.Background(), []byte(test.values), Options{})
+		if test.expected == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestGenerateDiagnostic(t *testing.T) {
+	tests := []struct {
+		values  string
+		line    int
+		column  int
+		pointer string
+	}{
+		{
+			values: `
+# @schema
+# minLength: -1
+# @schema
+name: foo
+`,
+			line:    5,
+			column:  1,
+			pointer: "/properties/name",
+		},
+		{
+			values: `
+app:
+  service:
+    # @schema
+    # type: integer
+    # minimum: foo: bar
+    # @schema
+    port: 8080
+`,
+			line:    6,
+			column:  0,
+			pointer: "/properties/app/properties/service/properties/port",
+		},
+		{
+			values: `
+app:
+  # @schema
+  # type: doesnotexist
+  # @schema
+  a/b: 1
+`,
+			line:    6,
+			column:  3,
+			pointer: "/properties/app/properties/a~1b",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := Generate(context.Background(), []byte(test.values), Options{ValuesPath: "values.yaml"})
+
+		var diag *Diagnostic
+		if !errors.As(err, &diag) {
+			t.Fatalf("expected a *Diagnostic, got %v", err)
+		}
+		if diag.Severity != SeverityError {
+			t.Errorf("expected severity error, got %v", diag.Severity)
+		}
+		if diag.File != "values.yaml" || diag.Line != test.line || diag.Column != test.column {
+			t.Errorf("expected position values.yaml:%d:%d, got %s", test.line, test.column, diag.Position())
+		}
+		if diag.Pointer != test.pointer {
+			t.Errorf("expected pointer %s, got %s", test.pointer, diag.Pointer)
+		}
+
+		var keyErr *KeyError
+		if !errors.As(err, &keyErr) || keyErr.Diagnostic != diag || !errors.Is(keyErr, diag.Kind) {
+			t.Errorf("expected the diagnostic to match as a *KeyError, got %v", keyErr)
+		}
+	}
+}
+
+func TestGenerateCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Generate(ctx, []byte("foo: bar"), Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestGenerateCollectsDiagnostics(t *testing.T) {
+	values := `
+# @schema
+# type: doesnotexist
+# @schema
+first: foo
+# @schema
+# minimum: foo: bar
+# @schema
+second: 1
+nested:
+  # @schema
+  # type: string
+  third: true
+`
+	tests := []struct {
+		maxErrors int
+		expected  int
+	}{
+		{maxErrors: 0, expected: 3},
+		{maxErrors: 2, expected: 3},
+		{maxErrors: 1, expected: 2},
+	}
+
+	for _, test := range tests {
+		res, err := Generate(context.Background(), []byte(values), Options{MaxErrors: test.maxErrors})
+
+		var diags Diagnostics
+		if !errors.As(err, &diags) {
+			t.Fatalf("expected Diagnostics, got %v", err)
+		}
+		if len(diags) != test.expected {
+			t.Errorf("maxErrors=%d: expected %d diagnostics, got %d:\n%v", test.maxErrors, test.expected, len(diags), diags)
+		}
+		if test.maxErrors > 0 && !errors.Is(err, ErrTooManyErrors) {
+			t.Errorf("maxErrors=%d: expected ErrTooManyErrors, got %v", test.maxErrors, err)
+		}
+		if test.maxErrors == 0 {
+			if second, _ := res.Properties.Get("second"); second.Type[0] != "integer" {
+				t.Errorf("expected broken key to fall back to inferred type, got %v", second.Type)
+			}
+		}
+	}
+}
+
+func TestGenerateDraft(t *testing.T) {
+	for _, name := range []string{"draft-07", "2019-09", "2020-12"} {
+		draft, err := ParseDraft(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := Generate(context.Background(), []byte("foo: bar"), Options{Draft: draft})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if res.Schema != draft.URI() {
+			t.Errorf("%s: expected $schema %s, got %s", name, draft.URI(), res.Schema)
+		}
+	}
+
+	if _, err := ParseDraft("draft-03"); err == nil {
+		t.Errorf("expected draft-03 to be rejected")
+	}
+	if _, err := Generate(context.Background(), []byte("foo: bar"), Options{Draft: "draft-03"}); err == nil {
+		t.Errorf("expected the draft-03 option to be rejected")
+	}
+	res, err := Generate(context.Background(), []byte("foo: bar"), Options{Draft: "2020"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Schema != Draft2020.URI() {
+		t.Errorf("expected the short draft name to select %s, got %s", Draft2020.URI(), res.Schema)
+	}
+}
+
+func TestGenerateClosedTuple(t *testing.T) {
+	values := `
+# @schema
+# type: array
+# items: [{type: string}]
+# additionalItems: false
+# @schema
+pair: [a]
+`
+	expected := map[Draft]string{
+		Draft7:    `{"additionalItems":false,"items":[{"type":"string"}],"title":"pair","type":"array"}`,
+		Draft2019: `{"additionalItems":false,"items":[{"type":"string"}],"title":"pair","type":"array"}`,
+		Draft2020: `{"items":false,"prefixItems":[{"type":"string"}],"title":"pair","type":"array"}`,
+	}
+	for draft, want := range expected {
+		res, err := Generate(context.Background(), []byte(values), Options{Draft: draft})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", draft, err)
+		}
+		pair, _ := res.Properties.Get("pair")
+		out, err := json.Marshal(pair)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != want {
+			t.Errorf("%s: expected the tuple to stay closed, got %s", draft, out)
+		}
+	}
+}
+
+func TestGenerateUnknownKeywords(t *testing.T) {
+	values := `
+# @schema
+# type: integer
+# maximun: 10
+# @schema
+replicas: 1
+`
+	var reported []*Diagnostic
+	_, err := Generate(context.Background(), []byte(values), Options{
+		Report: func(d *Diagnostic) { reported = append(reported, d) },
+	})
+	if err != nil {
+		t.Fatalf("expected unknown keywords to be warnings, got %v", err)
+	}
+	if len(reported) != 1 || reported[0].Severity != SeverityWarning || !errors.Is(reported[0], ErrUnknownKeyword) {
+		t.Fatalf("expected one unknown keyword warning, got %v", reported)
+	}
+	if reported[0].Line != 4 {
+		t.Errorf("expected the warning at line 4, got %d", reported[0].Line)
+	}
+
+	_, err = Generate(context.Background(), []byte(values), Options{Strict: true})
+	if !errors.Is(err, ErrUnknownKeyword) {
+		t.Errorf("expected unknown keywords to be errors in strict mode, got %v", err)
+	}
+}
+
+func TestGenerateDefaultNotInEnum(t *testing.T) {
+	tests := []struct {
+		values   string
+		expected error
+	}{
+		{
+			values: `
+# @schema
+# enum: [1, 2, 3]
+# @schema
+replicas: 2
+`,
+			expected: nil,
+		},
+		{
+			values: `
+# @schema
+# enum: [1, 2, 3]
+# @schema
+replicas: 5
+`,
+			expected: ErrInvalidDefault,
+		},
+		{
+			values: `
+# @schema
+# enum: [debug, info]
+# default: warn
+# @schema
+logLevel: info
+`,
+			expected: ErrInvalidDefault,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := Generate(context.Background(), []byte(test.values), Options{})
+		if test.expected == nil && err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if test.expected != nil && !errors.Is(err, test.expected) {
+			t.Errorf("expected error %v, got %v", test.expected, err)
+		}
+	}
+}
+
+func TestGenerateLint(t *testing.T) {
+	values := `
+# @schema
+# type: string
+# enum: [a, b]
+# @schema
+mode: a
+`
+	var reported []*Diagnostic
+	_, err := Generate(context.Background(), []byte(values), Options{
+		Report: func(d *Diagnostic) { reported = append(reported, d) },
+	})
+	if err != nil {
+		t.Fatalf("expected lint findings to be warnings, got %v", err)
+	}
+	if len(reported) != 1 || reported[0].Severity != SeverityWarning || !errors.Is(reported[0], ErrLint) {
+		t.Fatalf("expected one lint warning, got %v", reported)
+	}
+
+	reported = nil
+	_, err = Generate(context.Background(), []byte(values), Options{
+		DisabledLintRules: []string{"enum-with-type"},
+		Report:            func(d *Diagnostic) { reported = append(reported, d) },
+	})
+	if err != nil || len(reported) != 0 {
+		t.Errorf("expected no diagnostics with the rule disabled, got %v %v", err, reported)
+	}
+
+	if _, err := Generate(context.Background(), []byte(values), Options{DisabledLintRules: []string{"foo"}}); err == nil {
+		t.Errorf("expected an unknown lint rule to be rejected")
+	}
+}
+
+func TestGeneratePropertyOrder(t *testing.T) {
+	values := `
+zeta: 1
+alpha:
+  # @schema
+  # type: string
+  # x-order: 10
+  # @schema
+  second: b
+  first: a
+mid: true
+`
+	res, err := Generate(context.Background(), []byte(values), Options{OrderAnnotation: OrderAnnotation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := res.Properties.Keys(); !slices.Equal(keys, []string{"zeta", "alpha", "mid"}) {
+		t.Errorf("expected document order, got %v", keys)
+	}
+
+	out, err := res.ToJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zeta, alpha := bytes.Index(out, []byte(`"zeta"`)), bytes.Index(out, []byte(`"alpha"`)); zeta > alpha {
+		t.Errorf("expected zeta before alpha in the output:\n%s", out)
+	}
+
+	alpha, _ := res.Properties.Get("alpha")
+	second, _ := alpha.Properties.Get("second")
+	first, _ := alpha.Properties.Get("first")
+	if second.CustomAnnotations[OrderAnnotation] != 10 || first.CustomAnnotations[OrderAnnotation] != 2 {
+		t.Errorf("expected annotated position to be kept, got %v and %v",
+			second.CustomAnnotations[OrderAnnotation], first.CustomAnnotations[OrderAnnotation])
+	}
+
+	if _, err := Generate(context.Background(), []byte(values), Options{OrderAnnotation: "order"}); err == nil {
+		t.Errorf("expected an unsupported order annotation to be rejected")
+	}
+}
+
+func TestGeneratePolicy(t *testing.T) {
+	values := `
+# @schema
+# type: string
+# required: true
+# @schema
+name: foo
+labels:
+  app: foo
+# @schema
+# type: object
+# additionalProperties: true
+# @schema
+podAnnotations:
+  team:
+    owner: bar
+`
+	tests := []struct {
+		name             string
+		opts             Options
+		required         []string
+		labelsRequired   []string
+		rootAdditional   bool
+		labelsAdditional bool
+		teamAdditional   bool
+	}{
+		{
+			name:           "defaults",
+			required:       []string{"name", "labels"},
+			labelsRequired: []string{"app"},
+		},
+		{
+			name:           "explicit required",
+			opts:           Options{Required: RequiredExplicit},
+			required:       []string{"name"},
+			labelsRequired: []string{},
+		},
+		{
+			name:           "no required",
+			opts:           Options{Required: RequiredNone},
+			required:       []string{},
+			labelsRequired: []string{},
+		},
+		{
+			name:             "additional properties",
+			opts:             Options{AdditionalProperties: AdditionalPropertiesTrue},
+			required:         []string{"name", "labels"},
+			labelsRequired:   []string{"app"},
+			rootAdditional:   true,
+			labelsAdditional: true,
+			teamAdditional:   true,
+		},
+		{
+			name:           "inherited additional properties",
+			opts:           Options{AdditionalProperties: AdditionalPropertiesInherit},
+			required:       []string{"name", "labels"},
+			labelsRequired: []string{"app"},
+			teamAdditional: true,
+		},
+	}
+
+	// allowed reports whether a schema accepts unknown keys
+	allowed := func(s *Schema) bool {
+		b, ok := s.AdditionalProperties.(*bool)
+		return !ok || *b
+	}
+
+	for _, test := range tests {
+		res, err := Generate(context.Background(), []byte(values), test.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		labels, _ := res.Properties.Get("labels")
+		podAnnotations, _ := res.Properties.Get("podAnnotations")
+		team, _ := podAnnotations.Properties.Get("team")
+
+		if !slices.Equal(res.Required.Strings, test.required) {
+			t.Errorf("%s: expected required %v, got %v", test.name, test.required, res.Required.Strings)
+		}
+		if !slices.Equal(labels.Required.Strings, test.labelsRequired) {
+			t.Errorf("%s: expected labels required %v, got %v", test.name, test.labelsRequired, labels.Required.Strings)
+		}
+		if allowed(res) != test.rootAdditional || allowed(labels) != test.labelsAdditional || allowed(team) != test.teamAdditional {
+			t.Errorf("%s: expected additional properties root=%t labels=%t team=%t, got %t %t %t", test.name,
+				test.rootAdditional, test.labelsAdditional, test.teamAdditional, allowed(res), allowed(labels), allowed(team))
+		}
+	}
+
+	if _, err := Generate(context.Background(), []byte(values), Options{AdditionalProperties: "maybe"}); err == nil {
+		t.Errorf("expected an unsupported additionalProperties mode to be rejected")
+	}
+}
+
+func TestGenerateSubtree(t *testing.T) {
+	values := `
+# @schema
+# type: object
+# x-subtree: {additionalProperties: true, required: false}
+# @schema
+podAnnotations:
+  team:
+    owner: bar
+labels:
+  app: foo
+`
+	res, err := Generate(context.Background(), []byte(values), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	podAnnotations, _ := res.Properties.Get("podAnnotations")
+	team, _ := podAnnotations.Properties.Get("team")
+	labels, _ := res.Properties.Get("labels")
+
+	if podAnnotations.AdditionalProperties != nil || team.AdditionalProperties != nil {
+		t.Errorf("expected the subtree to accept unknown keys, got %v and %v",
+			podAnnotations.AdditionalProperties, team.AdditionalProperties)
+	}
+	if len(podAnnotations.Required.Strings) != 0 || len(team.Required.Strings) != 0 {
+		t.Errorf("expected no required keys in the subtree, got %v and %v",
+			podAnnotations.Required.Strings, team.Required.Strings)
+	}
+	if _, ok := podAnnotations.CustomAnnotations[SubtreeAnnotation]; ok {
+		t.Errorf("expected the subtree directives not to be emitted")
+	}
+	if allowed, ok := labels.AdditionalProperties.(*bool); !ok || *allowed || !slices.Equal(labels.Required.Strings, []string{"app"}) {
+		t.Errorf("expected the siblings to keep the default policy, got %v %v", labels.AdditionalProperties, labels.Required.Strings)
+	}
+
+	_, err = Generate(context.Background(), []byte(`
+# @schema
+# type: object
+# x-subtree: {required: maybe}
+# @schema
+podAnnotations: {}
+`), Options{})
+	if !errors.Is(err, ErrInvalidAnnotation) {
+		t.Errorf("expected an invalid directive to be reported, got %v", err)
+	}
+}
+
+func TestGenerateSkipPassthroughInternal(t *testing.T) {
+	values := `
+# @schema
+# skip: true
+# @schema
+debug: true
+# Free form configuration
+# @schema
+# x-passthrough: true
+# required: true
+# @schema
+config:
+  nested:
+    key: value
+# @schema
+# type: string
+# x-internal: true
+# @schema
+checksum: abc
+`
+	res, err := Generate(context.Background(), []byte(values), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := res.Properties.Keys(); !slices.Equal(keys, []string{"config", "checksum"}) {
+		t.Errorf("expected the skipped key to be omitted, got %v", keys)
+	}
+	if !slices.Equal(res.Required.Strings, []string{"config"}) {
+		t.Errorf("expected the pass-through key to be required, got %v", res.Required.Strings)
+	}
+
+	config, _ := res.Properties.Get("config")
+	out, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"description":"Free form configuration","title":"config"}` {
+		t.Errorf("expected an unconstrained schema, got %s", out)
+	}
+
+	checksum, _ := res.Properties.Get("checksum")
+	if !checksum.ReadOnly || checksum.CustomAnnotations[InternalAnnotation] != true {
+		t.Errorf("expected the internal key to be readOnly and annotated, got %v %v", checksum.ReadOnly, checksum.CustomAnnotations)
+	}
+}
+
+func TestGenerateRootAnnotation(t *testing.T) {
+	tests := []struct {
+		name   string
+		values string
+	}{
+		{
+			name: "document comment",
+			values: `# @schema.root
+# $id: https://example.com/values.schema.json
+# title: Values
+# description: Chart values
+# additionalProperties: true
+# required: [extra]
+# x-chart: demo
+# $defs:
+#   port: {type: integer}
+# @schema.root
+
+# Name of the release
+name: foo
+`,
+		},
+		{
+			name: "first key comment",
+			values: `# @schema.root
+# $id: https://example.com/values.schema.json
+# title: Values
+# description: Chart values
+# additionalProperties: true
+# required: [extra]
+# x-chart: demo
+# $defs:
+#   port: {type: integer}
+# @schema.root
+# Name of the release
+name: foo
+`,
+		},
+	}
+
+	for _, test := range tests {
+		res, err := Generate(context.Background(), []byte(test.values), Options{Draft: Draft2020})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if res.Id != "https://example.com/values.schema.json" || res.Title != "Values" || res.Description != "Chart values" {
+			t.Errorf("%s: expected the root keywords to be set, got %q %q %q", test.name, res.Id, res.Title, res.Description)
+		}
+		if allowed, ok := res.AdditionalProperties.(*bool); !ok || !*allowed {
+			t.Errorf("%s: expected additional properties to be allowed, got %v", test.name, res.AdditionalProperties)
+		}
+		if !slices.Equal(res.Required.Strings, []string{"extra", "name"}) {
+			t.Errorf("%s: expected required to merge the annotation and the values, got %v", test.name, res.Required.Strings)
+		}
+		if res.CustomAnnotations["x-chart"] != "demo" || res.Defs["port"] == nil || !slices.Equal(res.Type, []string{"object"}) {
+			t.Errorf("%s: expected the root annotations, $defs and type, got %v %v %v", test.name, res.CustomAnnotations, res.Defs, res.Type)
+		}
+		if name, _ := res.Properties.Get("name"); name.Description != "Name of the release" {
+			t.Errorf("%s: expected the key description to exclude the root block, got %q", test.name, name.Description)
+		}
+	}
+
+	var reported []*Diagnostic
+	_, err := Generate(context.Background(), []byte(`# @schema.root
+# minProperties: foo
+# @schema.root
+
+name: foo
+`), Options{Report: func(d *Diagnostic) { reported = append(reported, d) }})
+	if !errors.Is(err, ErrInvalidAnnotation) || len(reported) != 1 || reported[0].Line != 2 || reported[0].Pointer != "" {
+		t.Errorf("expected an invalid root annotation at line 2, got %v", reported)
+	}
+}
+
+func TestGenerateMergeKeys(t *testing.T) {
+	values := `
+defaults: &defaults
+  # @schema
+  # type: integer
+  # minimum: 1
+  # @schema
+  replicas: 1
+  image: nginx
+extra: &extra
+  image: httpd
+  debug: false
+single:
+  <<: *defaults
+  name: single
+list:
+  name: list
+  <<: [*extra, *defaults]
+override:
+  image: custom
+  <<: *defaults
+`
+	res, err := Generate(context.Background(), []byte(values), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key   string
+		keys  []string
+		image string
+	}{
+		{key: "single", keys: []string{"replicas", "image", "name"}, image: "nginx"},
+		{key: "list", keys: []string{"name", "image", "debug", "replicas"}, image: "httpd"},
+		{key: "override", keys: []string{"image", "replicas"}, image: "custom"},
+	}
+	for _, test := range tests {
+		prop, _ := res.Properties.Get(test.key)
+		if keys := prop.Properties.Keys(); !slices.Equal(keys, test.keys) {
+			t.Errorf("%s: expected properties %v, got %v", test.key, test.keys, keys)
+		}
+		if image, _ := prop.Properties.Get("image"); image.Default != test.image {
+			t.Errorf("%s: expected image default %q, got %v", test.key, test.image, image.Default)
+		}
+		if replicas, _ := prop.Properties.Get("replicas"); replicas.Minimum == nil {
+			t.Errorf("%s: expected the merged annotation to be kept", test.key)
+		}
+	}
+}
+
+func TestGenerateAliasCycle(t *testing.T) {
+	for _, values := range []string{
+		"a: &x\n  b: *x\n",
+		"a: &x [*x]\n",
+		"a: &x\n  c: 1\n  <<: *x\n",
+	} {
+		for _, anchorDefs := range []bool{false, true} {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_, err := Generate(ctx, []byte(values), Options{AnchorDefs: anchorDefs})
+			cancel()
+			var diag *Diagnostic
+			if !errors.As(err, &diag) || !errors.Is(err, ErrInvalidDocument) || diag.Line == 0 {
+				t.Errorf("%q: expected the alias cycle to be reported at the alias, got %v", values, err)
+			}
+		}
+	}
+}
+
+func TestGenerateAnchorDefs(t *testing.T) {
+	values := `
+defaults: &defaults
+  image: nginx
+primary: *defaults
+secondary: *defaults
+`
+	res, err := Generate(context.Background(), []byte(values), Options{AnchorDefs: true, Draft: Draft2020})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"defaults", "primary", "secondary"} {
+		if prop, _ := res.Properties.Get(key); prop.Ref != "#/$defs/defaults" {
+			t.Errorf("%s: expected a reference to the anchor definition, got %q", key, prop.Ref)
+		}
+	}
+	if def := res.Defs["defaults"]; def == nil || def.Properties.Len() != 1 {
+		t.Errorf("expected a single definition of the anchored mapping, got %v", res.Defs)
+	}
+	if !slices.Equal(res.Required.Strings, []string{"defaults", "primary", "secondary"}) {
+		t.Errorf("expected the referencing keys to stay required, got %v", res.Required.Strings)
+	}
+}
+
+func TestGenerateDocuments(t *testing.T) {
+	values := `
+name: foo
+port: 80
+---
+name: bar
+port: http
+debug: true
+---
+name: foo
+port: 80
+`
+	schemas, err := GenerateAll(context.Background(), []byte(values), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 3 {
+		t.Fatalf("expected one schema per document, got %d", len(schemas))
+	}
+	if _, err := Generate(context.Background(), []byte(values), Options{}); !errors.Is(err, ErrInvalidDocument) {
+		t.Errorf("expected Generate to reject split documents, got %v", err)
+	}
+
+	res, err := Generate(context.Background(), []byte(values), Options{Documents: DocumentsOneOf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.OneOf) != 2 || res.Schema == "" || res.OneOf[0].Schema != "" {
+		t.Errorf("expected a oneOf over the distinct documents, got %d branches", len(res.OneOf))
+	}
+
+	res, err = Generate(context.Background(), []byte(values), Options{Documents: DocumentsMerge})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := res.Properties.Keys(); !slices.Equal(keys, []string{"name", "port", "debug"}) {
+		t.Errorf("expected the union of the properties, got %v", keys)
+	}
+	if !slices.Equal(res.Required.Strings, []string{"name", "port"}) {
+		t.Errorf("expected only the keys of all the documents to be required, got %v", res.Required.Strings)
+	}
+	if name, _ := res.Properties.Get("name"); name.Default != "foo" || len(name.AnyOf) != 0 {
+		t.Errorf("expected the first default of name to be kept, got %+v", name)
+	}
+	if port, _ := res.Properties.Get("port"); !slices.Equal(port.Type, []string{"integer", "string"}) {
+		t.Errorf("expected the different types of port to be combined, got %v", port.Type)
+	}
+
+	// documents of different kinds are combined with anyOf under a single $schema
+	values = `
+# @schema.root
+# $defs:
+#   port: {type: integer}
+# @schema.root
+name: foo
+---
+- a
+- b
+`
+	res, err = Generate(context.Background(), []byte(values), Options{Documents: DocumentsMerge, Draft: Draft2020})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.AnyOf) != 2 || res.Schema != Draft2020.URI() || res.Defs["port"] == nil {
+		t.Fatalf("expected the $schema and the definitions at the root, got %+v", res)
+	}
+	for _, branch := range res.AnyOf {
+		if branch.Schema != "" || len(branch.Defs) != 0 {
+			t.Errorf("expected the branches without $schema nor definitions, got %+v", branch)
+		}
+	}
+}
+
+func TestGenerateEmptyInput(t *testing.T) {
+	for _, values := range []string{"", "# just a comment\n", "# @schema.root\n# title: Empty\n# @schema.root\n"} {
+		res, err := Generate(context.Background(), []byte(values), Options{Draft: Draft2020})
+		if err != nil {
+			t.Fatalf("%q: %v", values, err)
+		}
+		if res.Schema != Draft2020.URI() || !slices.Equal(res.Type, []string{"object"}) {
+			t.Errorf("%q: expected a document schema of the selected draft, got %q %v", values, res.Schema, res.Type)
+		}
+		if strings.Contains(values, "@schema.root") && res.Title != "Empty" {
+			t.Errorf("%q: expected the root annotation to apply, got %q", values, res.Title)
+		}
+	}
+}
+
+func TestGenerateDocumentsOneOfValidation(t *testing.T) {
+	values := `
+a: 1
+---
+b: x
+---
+a: 2
+`
+	res, err := Generate(context.Background(), []byte(values), Options{Documents: DocumentsOneOf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.OneOf) != 2 {
+		t.Errorf("expected the documents differing only by defaults to share a branch, got %d branches", len(res.OneOf))
+	}
+	for _, document := range []string{"a: 5", "b: y"} {
+		if err := validateValues(res, document); err != nil {
+			t.Errorf("expected %q to be valid: %v", document, err)
+		}
+	}
+	if err := validateValues(res, "c: 1"); err == nil {
+		t.Errorf("expected an unknown document to be rejected")
+	}
+}
+
+// validateValues validates the YAML values against the generated schema s
+func validateValues(s *Schema, values string) error {
+	out, err := s.ToJson()
+	if err != nil {
+		return err
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(out))
+	if err != nil {
+		return err
+	}
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("values.schema.json", doc); err != nil {
+		return err
+	}
+	compiled, err := compiler.Compile("values.schema.json")
+	if err != nil {
+		return err
+	}
+
+	var obj any
+	if err := yaml.Unmarshal([]byte(values), &obj); err != nil {
+		return err
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return compiled.Validate(instance)
+}
+
+// items returns the schema of the items of the array schema s
+func items(t *testing.T, s *Schema) *Schema {
+	t.Helper()
+	items, ok := s.Items.(*Schema)
+	if !ok {
+		t.Fatalf("expected a schema for the items, got %#v", s.Items)
+	}
+	return items
+}
+
+func TestGenerateNonMappingRoot(t *testing.T) {
+	res, err := Generate(context.Background(), []byte(`
+- name: acme
+  plan: gold
+- name: globex
+  plan: silver
+`), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Type, []string{"array"}) || res.Items == nil {
+		t.Fatalf("expected an array of the listed items, got %+v", res)
+	}
+	if item := items(t, res); !slices.Equal(item.Required.Strings, []string{"name", "plan"}) || item.Properties.Len() != 2 {
+		t.Errorf("expected the items to be inferred as nested sequences, got %+v", item)
+	}
+	if res.AdditionalProperties != nil || res.Properties != nil {
+		t.Errorf("expected no object keywords on an array root")
+	}
+
+	res, err = Generate(context.Background(), []byte(`# @schema.root
+# title: Port
+# @schema.root
+8080
+`), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Type, []string{"integer"}) || res.Default != 8080 || res.Title != "Port" || res.Schema == "" {
+		t.Errorf("expected an integer schema with its default, got %+v", res)
+	}
+}
+
+func TestGenerateItemsInference(t *testing.T) {
+	values := `
+tenants:
+  - name: acme
+    plan: gold
+  - name: globex
+    region: eu
+ports: [80, 8.5, 443]
+mixed:
+  - name: foo
+  - bar
+  - 1
+  - [1, 2]
+  - [true]
+`
+	res, err := Generate(context.Background(), []byte(values), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenants, _ := res.Properties.Get("tenants")
+	if keys := items(t, tenants).Properties.Keys(); !slices.Equal(keys, []string{"name", "plan", "region"}) {
+		t.Errorf("expected the union of the item properties, got %v", keys)
+	}
+	if !slices.Equal(items(t, tenants).Required.Strings, []string{"name"}) {
+		t.Errorf("expected only the keys of every item to be required, got %v", items(t, tenants).Required.Strings)
+	}
+
+	ports, _ := res.Properties.Get("ports")
+	if !slices.Equal(items(t, ports).Type, []string{"number"}) || len(items(t, ports).AnyOf) != 0 {
+		t.Errorf("expected the item types to be widened to number, got %+v", ports.Items)
+	}
+
+	mixed, _ := res.Properties.Get("mixed")
+	if len(items(t, mixed).AnyOf) != 3 {
+		t.Fatalf("expected one branch per shape, got %d", len(items(t, mixed).AnyOf))
+	}
+	if scalars := items(t, mixed).AnyOf[1]; !slices.Equal(scalars.Type, []string{"integer", "string"}) {
+		t.Errorf("expected the scalar items to share a branch, got %v", scalars.Type)
+	}
+	if lists := items(t, mixed).AnyOf[2]; !slices.Equal(items(t, lists).Type, []string{"boolean", "integer"}) {
+		t.Errorf("expected the list items to be merged, got %+v", lists.Items)
+	}
+}
+
+func TestGeneratePlaceholders(t *testing.T) {
+	values := `
+labels: {}
+extraEnv: []
+image:
+# @schema
+# x-type: string
+# @schema
+tag: null
+`
+	property := func(t *testing.T, res *Schema, name string) *Schema {
+		t.Helper()
+		prop, ok := res.Properties.Get(name)
+		if !ok {
+			t.Fatalf("missing property %s", name)
+		}
+		return prop
+	}
+
+	res, err := Generate(context.Background(), []byte(values), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels := property(t, res, "labels"); labels.AdditionalProperties == nil {
+		t.Errorf("expected an exact empty mapping to reject unknown keys")
+	}
+	if extraEnv := property(t, res, "extraEnv"); extraEnv.Items != nil || extraEnv.MaxItems != nil {
+		t.Errorf("expected an open empty list to accept any item, got %+v", extraEnv)
+	}
+	if image := property(t, res, "image"); !slices.Equal(image.Type, []string{"null"}) || image.Default != nil {
+		t.Errorf("expected an exact null of type null without default, got %+v", image)
+	}
+	tag := property(t, res, "tag")
+	if !slices.Equal(tag.Type, []string{"string"}) || tag.Title != "tag" || tag.Default != nil {
+		t.Errorf("expected the pinned type to keep the inference, got %+v", tag)
+	}
+	if !slices.Contains(res.Required.Strings, "tag") {
+		t.Errorf("expected the pinned key to be required by default, got %v", res.Required.Strings)
+	}
+	if _, ok := tag.CustomAnnotations[TypeAnnotation]; ok {
+		t.Errorf("expected %s not to be emitted", TypeAnnotation)
+	}
+
+	res, err = Generate(context.Background(), []byte(values), Options{
+		EmptyMaps:  PlaceholderOpen,
+		EmptyLists: PlaceholderExact,
+		Nulls:      PlaceholderOpen,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels := property(t, res, "labels"); labels.AdditionalProperties != nil {
+		t.Errorf("expected an open empty mapping to accept any key, got %v", labels.AdditionalProperties)
+	}
+	if extraEnv := property(t, res, "extraEnv"); extraEnv.MaxItems == nil || *extraEnv.MaxItems != 0 {
+		t.Errorf("expected an exact empty list to accept no item, got %+v", extraEnv)
+	}
+	if image := property(t, res, "image"); len(image.Type) != 0 {
+		t.Errorf("expected an open null to accept any value, got %v", image.Type)
+	}
+	if tag := property(t, res, "tag"); !slices.Equal(tag.Type, []string{"string"}) {
+		t.Errorf("expected the pinned type to win over the nulls mode, got %v", tag.Type)
+	}
+
+	if _, err := Generate(context.Background(), []byte(values), Options{Nulls: "any"}); err == nil {
+		t.Errorf("expected an unsupported placeholder mode to fail")
+	}
+}
+
+// writeFixtures writes the given files, keyed by their slash separated path,
+// to a temporary directory and returns it
+func writeFixtures(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGenerateNestedRefs(t *testing.T) {
+	dir := writeFixtures(t, map[string]string{
+		"schemas/resources.schema.json": `{
+  "type": "object",
+  "properties": {
+    "limits": {"$ref": "#/definitions/quantities"},
+    "requests": {"$ref": "quantities.schema.json"}
+  },
+  "definitions": {"quantities": {"type": "object", "additionalProperties": {"type": "string"}}}
+}`,
+		"schemas/quantities.schema.json": `{"type": "object", "additionalProperties": {"type": "string"}}`,
+		"schemas/cycle.schema.json":      `{"type": "array", "items": {"$ref": "#"}}`,
+		"schemas/loop.schema.json":       `{"$ref": "sub/loop.yaml#/loop"}`,
+		"schemas/sub/loop.yaml":          "loop:\n  $ref: ../loop.schema.json\n",
+	})
+
+	values := `
+# @schema
+# type: array
+# items:
+#   $ref: schemas/resources.schema.json
+# @schema
+containers: []
+# @schema
+# type: object
+# additionalProperties:
+#   $ref: schemas/quantities.schema.json
+# @schema
+quotas: {}
+`
+	res, err := Generate(context.Background(), []byte(values), Options{
+		ValuesPath: filepath.Join(dir, "values.yaml"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers, _ := res.Properties.Get("containers")
+	for _, name := range []string{"limits", "requests"} {
+		prop, ok := items(t, containers).Properties.Get(name)
+		if !ok || prop.Ref != "" || !slices.Equal(prop.Type, []string{"object"}) {
+			t.Errorf("expected %s to be resolved relative to the referenced file, got %+v", name, prop)
+		}
+	}
+	quotas, _ := res.Properties.Get("quotas")
+	if additional, ok := quotas.AdditionalProperties.(*Schema); !ok || additional.Ref != "" {
+		t.Errorf("expected the additionalProperties reference to be resolved, got %+v", quotas.AdditionalProperties)
+	}
+
+	values = `
+# @schema
+# $ref: schemas/cycle.schema.json
+# @schema
+tree: []
+`
+	_, err = Generate(context.Background(), []byte(values), Options{
+		ValuesPath: filepath.Join(dir, "values.yaml"),
+	})
+	if !errors.Is(err, ErrInvalidRef) || !strings.Contains(err.Error(), "reference cycle") {
+		t.Errorf("expected a reference cycle error, got %v", err)
+	}
+
+	// bundled definitions can be recursive, but not only point at each other
+	values = `
+# @schema
+# $ref: schemas/loop.schema.json
+# @schema
+loop: {}
+`
+	for _, bundle := range []bool{false, true} {
+		_, err = Generate(context.Background(), []byte(values), Options{
+			ValuesPath: filepath.Join(dir, "values.yaml"),
+			BundleRefs: bundle,
+		})
+		if !errors.Is(err, ErrInvalidRef) || !strings.Contains(err.Error(), "reference cycle") {
+			t.Errorf("bundle %v: expected a reference cycle error, got %v", bundle, err)
+		}
+	}
+}
+
+func TestGenerateBundleRefs(t *testing.T) {
+	dir := writeFixtures(t, map[string]string{
+		"resources.schema.json": `{
+  "type": "object",
+  "properties": {"limits": {"$ref": "#/definitions/quantities"}},
+  "definitions": {"quantities": {"type": "object"}}
+}`,
+		"tree.schema.json": `{"type": "array", "items": {"$ref": "#"}}`,
+	})
+
+	values := `
+# @schema
+# $ref: resources.schema.json
+# title: Resources
+# @schema
+resources: {}
+# @schema
+# type: array
+# items:
+#   $ref: resources.schema.json
+# @schema
+sidecars: []
+# @schema
+# $ref: tree.schema.json
+# @schema
+tree: []
+`
+	opts := Options{ValuesPath: filepath.Join(dir, "values.yaml"), BundleRefs: true, Draft: Draft2020}
+	res, err := Generate(context.Background(), []byte(values), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := slices.Sorted(maps.Keys(res.Defs)); !slices.Equal(keys, []string{"quantities", "resources", "tree"}) {
+		t.Fatalf("expected each referenced schema to be bundled once, got %v", keys)
+	}
+	if limits, _ := res.Defs["resources"].Properties.Get("limits"); limits.Ref != "#/$defs/quantities" {
+		t.Errorf("expected the local reference of the file to be bundled, got %q", limits.Ref)
+	}
+	if tree := res.Defs["tree"]; items(t, tree).Ref != "#/$defs/tree" {
+		t.Errorf("expected the recursive reference to point to its definition, got %q", items(t, tree).Ref)
+	}
+	resources, _ := res.Properties.Get("resources")
+	if resources.Ref != "#/$defs/resources" || resources.Title != "Resources" {
+		t.Errorf("expected the reference to keep its sibling keywords, got %+v", resources)
+	}
+	if sidecars, _ := res.Properties.Get("sidecars"); items(t, sidecars).Ref != "#/$defs/resources" {
+		t.Errorf("expected the nested reference to be bundled, got %q", items(t, sidecars).Ref)
+	}
+
+	opts.Draft = Draft7
+	res, err = Generate(context.Background(), []byte(values), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources, _ = res.Properties.Get("resources")
+	if resources.Ref != "" || len(resources.AllOf) != 1 || resources.AllOf[0].Ref != "#/$defs/resources" {
+		t.Errorf("expected draft-07 sibling keywords to be kept next to an allOf, got %+v", resources)
+	}
+	out, err := res.ToJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out, []byte(`"$ref": "#/definitions/resources"`)) {
+		t.Errorf("expected draft-07 references to definitions, got %s", out)
+	}
+}
+
+func TestGenerateYAMLRefs(t *testing.T) {
+	dir := writeFixtures(t, map[string]string{
+		"ingress.schema.yaml": `
+type: object
+properties:
+  tls:
+    $ref: "#/definitions/tls"
+definitions:
+  tls:
+    type: array
+    items:
+      $ref: common.schema#/definitions/secret
+`,
+		// no known extension: the format is sniffed from the content
+		"common.schema": `
+definitions:
+  secret:
+    type: object
+    properties:
+      secretName: {type: string, minLength: 1}
+`,
+	})
+
+	values := `
+# @schema
+# $ref: ingress.schema.yaml#/definitions/tls
+# @schema
+tls: []
+`
+	res, err := Generate(context.Background(), []byte(values), Options{ValuesPath: filepath.Join(dir, "values.yaml")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tls, _ := res.Properties.Get("tls")
+	if !slices.Equal(tls.Type, []string{"array"}) || tls.Items == nil {
+		t.Fatalf("expected the YAML definition to be resolved, got %+v", tls)
+	}
+	secretName, ok := items(t, tls).Properties.Get("secretName")
+	if !ok || secretName.MinLength == nil || *secretName.MinLength != 1 {
+		t.Errorf("expected the sniffed YAML file to be resolved, got %+v", tls.Items)
+	}
+}
+
+func TestGenerateLocalRefs(t *testing.T) {
+	values := `
+# @schema
+# $ref: "#/properties/git/properties/fromRepo"
+# description: The target repository
+# @schema
+target: {}
+git:
+  fromRepo:
+    url: https://example.com
+    branch: main
+`
+	res, err := Generate(context.Background(), []byte(values), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, _ := res.Properties.Get("target")
+	if target.Ref != "" || !slices.Equal(target.Properties.Keys(), []string{"url", "branch"}) {
+		t.Errorf("expected a copy of the key declared later, got %+v", target)
+	}
+	if target.Title != "" || target.Description != "The target repository" {
+		t.Errorf("expected the annotations of the referencing key, got %q %q", target.Title, target.Description)
+	}
+
+	res, err = Generate(context.Background(), []byte(values), Options{BundleRefs: true, Draft: Draft2020})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target, _ := res.Properties.Get("target"); target.Ref != "#/properties/git/properties/fromRepo" {
+		t.Errorf("expected the internal reference to be kept, got %+v", target)
+	}
+
+	values = `
+# @schema
+# $ref: "#/properties/b"
+# @schema
+a: 1
+# @schema
+# $ref: "#/properties/a"
+# @schema
+b: 1
+# @schema
+# $ref: "#/properties/missing"
+# @schema
+c: 1
+`
+	_, err = Generate(context.Background(), []byte(values), Options{})
+	var diags Diagnostics
+	if !errors.As(err, &diags) || len(diags) != 3 {
+		t.Fatalf("expected an error per broken reference, got %v", err)
+	}
+	if !strings.Contains(diags[0].Error(), "reference cycle") || !strings.Contains(diags[2].Error(), "no schema found") {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
+
+func TestGenerateDefsLibrary(t *testing.T) {
+	dir := writeFixtures(t, map[string]string{"defs.yaml": `
+$defs:
+  secretRef:
+    type: object
+    properties:
+      name: {type: string}
+      key: {type: string}
+  image:
+    type: object
+    properties:
+      repository: {type: string}
+      pullSecret: {$ref: "#/$defs/secretRef"}
+  unused: {type: string}
+`})
+	defsFile := filepath.Join(dir, "defs.yaml")
+
+	values := `# @schema.root
+# $defs:
+#   port: {type: integer, minimum: 1}
+#   other: {type: boolean}
+# @schema.root
+
+# @schema
+# x-use: port
+# title: HTTP port
+# @schema
+httpPort: 8080
+# @schema
+# $ref: "#/$defs/image"
+# @schema
+image: {}
+`
+	res, err := Generate(context.Background(), []byte(values), Options{DefsFile: defsFile, Draft: Draft2020})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the definitions of the root annotation are kept, only the used ones of the file are added
+	if keys := slices.Sorted(maps.Keys(res.Defs)); !slices.Equal(keys, []string{"image", "other", "port", "secretRef"}) {
+		t.Errorf("expected the root and the used definitions, got %v", keys)
+	}
+	httpPort, _ := res.Properties.Get("httpPort")
+	if httpPort.Ref != "#/$defs/port" || httpPort.Title != "HTTP port" {
+		t.Errorf("expected %s to reference the definition, got %+v", UseAnnotation, httpPort)
+	}
+	if _, ok := httpPort.CustomAnnotations[UseAnnotation]; ok {
+		t.Errorf("expected %s not to be emitted", UseAnnotation)
+	}
+
+	values = `
+# @schema
+# x-use: missing
+# @schema
+port: 80
+`
+	_, err = Generate(context.Background(), []byte(values), Options{DefsFile: defsFile})
+	if !errors.Is(err, ErrInvalidRef) || !strings.Contains(err.Error(), `unknown definition "missing"`) {
+		t.Errorf("expected an unknown definition error, got %v", err)
+	}
+
+	values = `# @schema.root
+# $defs:
+#   port: {type: integer, minimum: 1}
+# @schema.root
+anchored: &anchored {a: 1}
+copy: *anchored
+# @schema
+# $ref: "#/$defs/prot"
+# @schema
+port: 80
+# @schema
+# $ref: "#/definitions/port"
+# @schema
+other: 80
+`
+	_, err = Generate(context.Background(), []byte(values), Options{DefsFile: defsFile, AnchorDefs: true})
+	var diags Diagnostics
+	if !errors.As(err, &diags) || len(diags) != 1 {
+		t.Fatalf("expected a single diagnostic, got %v", err)
+	}
+	if diags[0].Line != 10 || !errors.Is(diags[0], ErrInvalidRef) || !strings.Contains(diags[0].Error(), `unknown definition "prot"`) {
+		t.Errorf("expected the unknown definition to be reported at its annotation, got %v", diags[0])
+	}
+}